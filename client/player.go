@@ -150,7 +150,7 @@ func ClientCheckMovement(context *ClientContext, deltaTime float32) {
 
 func (clientContext *ClientContext) ClientOnClick(button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
 
-	Log.NewLog("Clicked, Button:", button, "Action:", action, "Mods:", mods)
+	Log.Client.Debug("Mouse button", "button", button, "action", action, "mods", mods)
 
 }
 
@@ -0,0 +1,35 @@
+package gpu
+
+import "github.com/go-gl/gl/v4.6-core/gl"
+
+/* -- [[ Shader Storage Buffer Bindings ]] -- */
+
+// Every SSBO binding point used by the shaders, emitted into the generated GLSL by tools/glslgen
+
+const (
+	BindingNodes uint32 = iota
+	BindingChunkInfo
+	BindingDisplacements
+	BindingDebug
+	BindingMaterials
+	BindingLights
+)
+
+type SSBOBinding struct {
+	Block   string // Name of the buffer block in GLSL
+	Name    string // Name of the constant in GLSL
+	Binding uint32
+}
+
+var SSBOBindings = []SSBOBinding{
+	{"NodeBuffer", "BINDING_NODES", BindingNodes},
+	{"ChunkInfoBuffer", "BINDING_CHUNK_INFO", BindingChunkInfo},
+	{"Offsets", "BINDING_DISPLACEMENTS", BindingDisplacements},
+	{"DebugResult", "BINDING_DEBUG", BindingDebug},
+	{"MaterialBuffer", "BINDING_MATERIALS", BindingMaterials},
+	{"LightBuffer", "BINDING_LIGHTS", BindingLights},
+}
+
+func BindStorageBuffer(binding uint32, buffer uint32) {
+	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, binding, buffer)
+}
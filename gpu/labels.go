@@ -0,0 +1,34 @@
+package gpu
+
+import "github.com/go-gl/gl/v4.6-core/gl"
+
+/* -- [[ Object Labels ]] -- */
+
+// Labels name GL objects in debug messages and in tools like RenderDoc. They need GL 4.3 or
+// KHR_debug, so they stay off until the debug output setup finds support.
+
+var LabelsEnabled bool
+
+// Label names an object, identifier is its kind such as gl.BUFFER, gl.FRAMEBUFFER or gl.PROGRAM
+func Label(identifier uint32, name uint32, label string) {
+
+	if !LabelsEnabled || name == 0 {
+		return
+	}
+
+	gl.ObjectLabel(identifier, name, -1, gl.Str(label+"\x00"))
+
+}
+
+// LabelBuffer binds a freshly generated buffer once, a name only becomes an object when first bound
+func LabelBuffer(target uint32, buffer uint32, label string) {
+
+	if !LabelsEnabled || buffer == 0 {
+		return
+	}
+
+	gl.BindBuffer(target, buffer)
+	Label(gl.BUFFER, buffer, label)
+	gl.BindBuffer(target, 0)
+
+}
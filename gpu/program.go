@@ -0,0 +1,236 @@
+package gpu
+
+import (
+	"strings"
+
+	Log "VoxelRPG/logging"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/* -- [[ Shader Program ]] -- */
+
+// Program caches the locations of the active uniforms once after linking, setters write
+// straight to the program so it does not need to be bound first.
+
+type Uniform struct {
+	Location int32
+	Type     uint32
+	Size     int32 // Array length, 1 for non arrays
+}
+
+type Program struct {
+	ID   uint32
+	Name string
+
+	Uniforms map[string]Uniform
+	Blocks   map[string]uint32 // SSBO block name to its binding point
+
+	warned map[string]bool
+}
+
+func NewProgram(id uint32, name string) *Program {
+
+	p := &Program{
+		ID:   id,
+		Name: name,
+
+		Uniforms: map[string]Uniform{},
+		Blocks:   map[string]uint32{},
+
+		warned: map[string]bool{},
+	}
+
+	Label(gl.PROGRAM, id, name)
+
+	p.reflectUniforms()
+	p.reflectBlocks()
+
+	return p
+
+}
+
+func (p *Program) reflectUniforms() {
+
+	var count, maxLength int32
+	gl.GetProgramiv(p.ID, gl.ACTIVE_UNIFORMS, &count)
+	gl.GetProgramiv(p.ID, gl.ACTIVE_UNIFORM_MAX_LENGTH, &maxLength)
+
+	buffer := make([]uint8, maxLength+1)
+
+	for i := uint32(0); i < uint32(count); i++ {
+
+		var length, size int32
+		var xtype uint32
+
+		gl.GetActiveUniform(p.ID, i, int32(len(buffer)), &length, &size, &xtype, &buffer[0])
+
+		// Arrays are reported as "name[0]"
+
+		name := strings.TrimSuffix(string(buffer[:length]), "[0]")
+
+		location := gl.GetUniformLocation(p.ID, gl.Str(name+"\x00"))
+
+		if location < 0 {
+			continue // Inside a uniform block
+		}
+
+		p.Uniforms[name] = Uniform{Location: location, Type: xtype, Size: size}
+
+	}
+
+}
+
+func (p *Program) reflectBlocks() {
+
+	var count, maxLength int32
+	gl.GetProgramInterfaceiv(p.ID, gl.SHADER_STORAGE_BLOCK, gl.ACTIVE_RESOURCES, &count)
+	gl.GetProgramInterfaceiv(p.ID, gl.SHADER_STORAGE_BLOCK, gl.MAX_NAME_LENGTH, &maxLength)
+
+	buffer := make([]uint8, maxLength+1)
+	property := uint32(gl.BUFFER_BINDING)
+
+	for i := uint32(0); i < uint32(count); i++ {
+
+		var length, binding int32
+
+		gl.GetProgramResourceName(p.ID, gl.SHADER_STORAGE_BLOCK, i, int32(len(buffer)), &length, &buffer[0])
+		gl.GetProgramResourceiv(p.ID, gl.SHADER_STORAGE_BLOCK, i, 1, &property, 1, nil, &binding)
+
+		p.Blocks[string(buffer[:length])] = uint32(binding)
+
+	}
+
+	// Buffers are bound by the registry, so a shader using another binding would read the wrong data
+
+	for block, binding := range p.Blocks {
+
+		expected, ok := bindingForBlock(block)
+
+		if !ok {
+			Log.Shader.Warn("Storage block is not in the binding registry", "program", p.Name, "block", block)
+			continue
+		}
+
+		if expected != binding {
+			Log.Shader.Warn("Storage block binding does not match the registry", "program", p.Name, "block", block, "binding", binding, "expected", expected)
+		}
+
+	}
+
+}
+
+func bindingForBlock(block string) (uint32, bool) {
+
+	for _, entry := range SSBOBindings {
+		if entry.Block == block {
+			return entry.Binding, true
+		}
+	}
+
+	return 0, false
+
+}
+
+func (p *Program) Use() {
+	gl.UseProgram(p.ID)
+}
+
+func (p *Program) Delete() {
+	gl.DeleteProgram(p.ID)
+}
+
+func (p *Program) Has(name string) bool {
+	_, ok := p.Uniforms[name]
+	return ok
+}
+
+// location warns once for names the program does not use, which includes uniforms optimised out
+func (p *Program) location(name string, types ...uint32) (int32, bool) {
+
+	uniform, ok := p.Uniforms[name]
+
+	if !ok {
+
+		if !p.warned[name] {
+			p.warned[name] = true
+			Log.Shader.Warn("Unknown or inactive uniform", "program", p.Name, "uniform", name)
+		}
+
+		return -1, false
+
+	}
+
+	for _, xtype := range types {
+		if uniform.Type == xtype {
+			return uniform.Location, true
+		}
+	}
+
+	if !p.warned[name] {
+		p.warned[name] = true
+		Log.Shader.Warn("Uniform set with the wrong type", "program", p.Name, "uniform", name)
+	}
+
+	return -1, false
+
+}
+
+/* -- [[ Typed Setters ]] -- */
+
+func (p *Program) SetMat4(name string, value mgl32.Mat4) {
+
+	if location, ok := p.location(name, gl.FLOAT_MAT4); ok {
+		gl.ProgramUniformMatrix4fv(p.ID, location, 1, false, &value[0])
+	}
+
+}
+
+func (p *Program) SetVec2(name string, value mgl32.Vec2) {
+
+	if location, ok := p.location(name, gl.FLOAT_VEC2); ok {
+		gl.ProgramUniform2f(p.ID, location, value[0], value[1])
+	}
+
+}
+
+func (p *Program) SetVec3(name string, value mgl32.Vec3) {
+
+	if location, ok := p.location(name, gl.FLOAT_VEC3); ok {
+		gl.ProgramUniform3f(p.ID, location, value[0], value[1], value[2])
+	}
+
+}
+
+func (p *Program) SetVec4(name string, value mgl32.Vec4) {
+
+	if location, ok := p.location(name, gl.FLOAT_VEC4); ok {
+		gl.ProgramUniform4f(p.ID, location, value[0], value[1], value[2], value[3])
+	}
+
+}
+
+func (p *Program) SetFloat(name string, value float32) {
+
+	if location, ok := p.location(name, gl.FLOAT); ok {
+		gl.ProgramUniform1f(p.ID, location, value)
+	}
+
+}
+
+func (p *Program) SetInt(name string, value int32) {
+
+	if location, ok := p.location(name, gl.INT, gl.BOOL, gl.SAMPLER_2D, gl.SAMPLER_3D); ok {
+		gl.ProgramUniform1i(p.ID, location, value)
+	}
+
+}
+
+func (p *Program) SetUint(name string, value uint32) {
+
+	if location, ok := p.location(name, gl.UNSIGNED_INT); ok {
+		gl.ProgramUniform1ui(p.ID, location, value)
+	}
+
+}
@@ -1,9 +1,352 @@
 package types
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
 
+/* -- [[ Levels ]] -- */
+
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l Level) String() string {
+
+	if l < LevelDebug || l > LevelError {
+		return "LEVEL(" + fmt.Sprint(int32(l)) + ")"
+	}
+
+	return levelNames[l]
+
+}
+
+func ParseLevel(name string) (Level, error) {
+
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return Level(level), nil
+		}
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+
+}
+
+/* -- [[ Entries ]] -- */
+
+type Field struct {
+	Key   string
+	Value any
+}
+
+type Entry struct {
+	Time      time.Time
+	Level     Level
+	Subsystem string
+	Message   string
+	Fields    []Field
+}
+
+// String formats an entry as one line, "15:04:05.000 INFO  world  message key=value"
+func (e Entry) String() string {
+
+	var line strings.Builder
+
+	fmt.Fprintf(&line, "%v %-5v %-7v %v", e.Time.Format("15:04:05.000"), e.Level, e.Subsystem, e.Message)
+
+	for _, field := range e.Fields {
+
+		value := fmt.Sprint(field.Value)
+
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+
+		fmt.Fprintf(&line, " %v=%v", field.Key, value)
+
+	}
+
+	return line.String()
+
+}
+
+// fields pairs up alternating keys and values, a value without a key is kept under "!BADKEY"
+func fields(keyValues []any) []Field {
+
+	if len(keyValues) == 0 {
+		return nil
+	}
+
+	output := make([]Field, 0, (len(keyValues)+1)/2)
+
+	for i := 0; i < len(keyValues); i += 2 {
+
+		key, ok := keyValues[i].(string)
+
+		if !ok || i+1 == len(keyValues) {
+			output = append(output, Field{Key: "!BADKEY", Value: keyValues[i]})
+			i--
+			continue
+		}
+
+		output = append(output, Field{Key: key, Value: keyValues[i+1]})
+
+	}
+
+	return output
+
+}
+
+/* -- [[ Loggers ]] -- */
+
+// A Logger tags its entries with a subsystem. Fields are given as alternating keys and values:
+//
+//	Log.World.Info("Chunk loaded", "position", chunk.Position, "nodes", len(nodes))
+type Logger struct {
+	Subsystem string
+
+	interval time.Duration // Minimum time between entries with the same message, 0 for no limit
+}
+
+var (
+	Main   = New("main")
+	World  = New("world")
+	GL     = New("gl")
+	Client = New("client")
+	Shader = New("shader")
+)
+
+func New(subsystem string) *Logger {
+	return &Logger{Subsystem: subsystem}
+}
+
+// Every returns a logger for hot paths that writes each message at most once per interval,
+// the next entry that gets through carries the number that were dropped in between
+func (l *Logger) Every(interval time.Duration) *Logger {
+	return &Logger{Subsystem: l.Subsystem, interval: interval}
+}
+
+// Enabled reports whether entries at a level would be written, to skip building expensive fields
+func (l *Logger) Enabled(level Level) bool {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return level >= levelFor(l.Subsystem)
+
+}
+
+func (l *Logger) Debug(message string, keyValues ...any) { l.Log(LevelDebug, message, keyValues...) }
+func (l *Logger) Info(message string, keyValues ...any)  { l.Log(LevelInfo, message, keyValues...) }
+func (l *Logger) Warn(message string, keyValues ...any)  { l.Log(LevelWarn, message, keyValues...) }
+func (l *Logger) Error(message string, keyValues ...any) { l.Log(LevelError, message, keyValues...) }
+
+func (l *Logger) Log(level Level, message string, keyValues ...any) {
+
+	now := time.Now()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if level < levelFor(l.Subsystem) {
+		return
+	}
+
+	entry := Entry{
+		Time:      now,
+		Level:     level,
+		Subsystem: l.Subsystem,
+		Message:   message,
+		Fields:    fields(keyValues),
+	}
+
+	if l.interval > 0 {
+
+		key := l.Subsystem + "\x00" + message
+		limit := limits[key]
+
+		if now.Sub(limit.last) < l.interval {
+			limit.dropped++
+			limits[key] = limit
+			return
+		}
+
+		if limit.dropped > 0 {
+			entry.Fields = append(entry.Fields, Field{Key: "suppressed", Value: limit.dropped})
+		}
+
+		limits[key] = rateLimit{last: now}
+
+	}
+
+	for _, sink := range sinks {
+		sink.Write(entry)
+	}
+
+}
+
+/* -- [[ Configuration ]] -- */
+
+type rateLimit struct {
+	last    time.Time
+	dropped int
+}
+
+var (
+	mutex sync.Mutex
+
+	defaultLevel    = LevelInfo
+	subsystemLevels = map[string]Level{}
+
+	limits = map[string]rateLimit{}
+
+	// Console keeps the latest entries for an in-game console
+	Console = NewRingSink(512)
+
+	sinks = []Sink{NewWriterSink(os.Stderr), Console}
+)
+
+// VOXELRPG_LOG sets the levels as a default and per subsystem overrides, "info,world=debug"
+func init() {
+
+	config := os.Getenv("VOXELRPG_LOG")
+
+	if config != "" {
+		if err := Configure(config); err != nil {
+			Main.Warn("VOXELRPG_LOG ignored", "error", err)
+		}
+	}
+
+	if file := os.Getenv("VOXELRPG_LOG_FILE"); file != "" {
+
+		sink, err := NewRotatingFileSink(file, DefaultMaxFileSize, DefaultMaxBackups)
+
+		if err != nil {
+			Main.Error("Could not open the log file", "file", file, "error", err)
+			return
+		}
+
+		AddSink(sink)
+
+	}
+
+}
+
+func levelFor(subsystem string) Level {
+
+	if level, ok := subsystemLevels[subsystem]; ok {
+		return level
+	}
+
+	return defaultLevel
+
+}
+
+func SetLevel(level Level) {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	defaultLevel = level
+
+}
+
+func SetSubsystemLevel(subsystem string, level Level) {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	subsystemLevels[subsystem] = level
+
+}
+
+// Configure parses a comma separated list of levels, a bare level sets the default
+func Configure(config string) error {
+
+	for _, part := range strings.Split(config, ",") {
+
+		subsystem, name, found := strings.Cut(strings.TrimSpace(part), "=")
+
+		if !found {
+			name = subsystem
+		}
+
+		level, err := ParseLevel(name)
+
+		if err != nil {
+			return err
+		}
+
+		if found {
+			SetSubsystemLevel(subsystem, level)
+		} else {
+			SetLevel(level)
+		}
+
+	}
+
+	return nil
+
+}
+
+func AddSink(sink Sink) {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	sinks = append(sinks, sink)
+
+}
+
+// SetSinks replaces every sink, including the defaults
+func SetSinks(replacement ...Sink) {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	sinks = replacement
+
+}
+
+// Close flushes and closes the sinks that hold files
+func Close() {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for _, sink := range sinks {
+		if closer, ok := sink.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}
+
+}
+
+/* -- [[ Compatibility ]] -- */
+
+// NewLog logs its arguments the way fmt.Println would print them, at info level under "main".
+// Messages starting with "ERROR:" are logged as errors.
 func NewLog(log ...any) {
 
-	fmt.Println(log...)
+	message := strings.TrimSpace(fmt.Sprintln(log...))
+	level := LevelInfo
+
+	if rest, found := strings.CutPrefix(message, "ERROR:"); found {
+		message = strings.TrimSpace(rest)
+		level = LevelError
+	}
+
+	Main.Log(level, message)
 
 }
@@ -0,0 +1,234 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLogs sends entries only to a ring sink for the test, restoring levels, limits and sinks after
+func captureLogs(t *testing.T) *RingSink {
+
+	mutex.Lock()
+
+	savedSinks, savedDefault, savedLevels, savedLimits := sinks, defaultLevel, subsystemLevels, limits
+
+	defaultLevel, subsystemLevels, limits = LevelInfo, map[string]Level{}, map[string]rateLimit{}
+
+	mutex.Unlock()
+
+	t.Cleanup(func() {
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		sinks, defaultLevel, subsystemLevels, limits = savedSinks, savedDefault, savedLevels, savedLimits
+
+	})
+
+	ring := NewRingSink(64)
+	SetSinks(ring)
+
+	return ring
+
+}
+
+// messages lists the entries as "subsystem message", with any fields after the message
+func messages(ring *RingSink) []string {
+
+	var output []string
+
+	for _, entry := range ring.Entries() {
+
+		line := entry.Subsystem + " " + entry.Message
+
+		for _, field := range entry.Fields {
+			line += fmt.Sprintf(" %v=%v", field.Key, field.Value)
+		}
+
+		output = append(output, line)
+
+	}
+
+	return output
+
+}
+
+func TestLevelFiltering(t *testing.T) {
+
+	tests := []struct {
+		config string
+		want   []string
+	}{
+		{"info", []string{"world info", "world warn", "gl info"}},
+		{"WARN", []string{"world warn"}},
+		{"error", nil},
+		{"info,world=debug", []string{"world debug", "world info", "world warn", "gl info"}},
+		{"debug, world=warn", []string{"world warn", "gl debug", "gl info"}},
+	}
+
+	for _, test := range tests {
+
+		ring := captureLogs(t)
+
+		if err := Configure(test.config); err != nil {
+			t.Errorf("%q: %v", test.config, err)
+			continue
+		}
+
+		world, gl := New("world"), New("gl")
+
+		world.Debug("world debug")
+		world.Info("world info")
+		world.Warn("world warn")
+		gl.Debug("gl debug")
+		gl.Info("gl info")
+
+		var got []string
+
+		for _, message := range messages(ring) {
+			got = append(got, strings.TrimPrefix(strings.TrimPrefix(message, "world "), "gl "))
+		}
+
+		if strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+			t.Errorf("%q logged %v, want %v", test.config, got, test.want)
+		}
+
+		if enabled := world.Enabled(LevelDebug); enabled != (len(test.want) > 0 && test.want[0] == "world debug") {
+			t.Errorf("%q: world debug enabled is %v", test.config, enabled)
+		}
+
+	}
+
+	captureLogs(t)
+
+	if err := Configure("info,world=loud"); err == nil {
+		t.Error("an unknown level was accepted")
+	}
+
+}
+
+func TestEveryRateLimit(t *testing.T) {
+
+	ring := captureLogs(t)
+
+	hot := New("world").Every(time.Hour)
+
+	for i := 0; i < 4; i++ {
+		hot.Info("hot path")
+	}
+
+	hot.Info("other path")
+	hot.Debug("below the level")
+
+	// Once the interval has passed the next entry reports how many were dropped
+
+	mutex.Lock()
+	limit := limits["world\x00hot path"]
+	limit.last = limit.last.Add(-time.Hour)
+	limits["world\x00hot path"] = limit
+	mutex.Unlock()
+
+	hot.Info("hot path")
+	hot.Info("hot path")
+
+	want := []string{"world hot path", "world other path", "world hot path suppressed=3"}
+
+	if got := messages(ring); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("rate limited logger wrote %v, want %v", got, want)
+	}
+
+}
+
+func TestRotatingFileSink(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "logs", "game.log")
+
+	entry := func(i int) Entry {
+		return Entry{Time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), Level: LevelInfo, Subsystem: "main", Message: fmt.Sprint("entry ", i)}
+	}
+
+	// Room for two lines per file
+
+	lineSize := int64(len(entry(1).String()) + 1)
+
+	sink, err := NewRotatingFileSink(file, 2*lineSize, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 7; i++ {
+		sink.Write(entry(i))
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]int{
+		file:        {7},
+		file + ".1": {5, 6},
+		file + ".2": {3, 4},
+	}
+
+	for path, entries := range want {
+
+		var lines string
+
+		for _, i := range entries {
+			lines += entry(i).String() + "\n"
+		}
+
+		data, err := os.ReadFile(path)
+
+		if err != nil || string(data) != lines {
+			t.Errorf("%v holds %q (%v), want %q", filepath.Base(path), data, err, lines)
+		}
+
+	}
+
+	if _, err := os.Stat(file + ".3"); !os.IsNotExist(err) {
+		t.Errorf("a third backup was kept: %v", err)
+	}
+
+}
+
+func TestRingSink(t *testing.T) {
+
+	tests := []struct {
+		capacity int
+		writes   int
+		want     []string
+	}{
+		{3, 2, []string{"1", "2"}},
+		{3, 3, []string{"1", "2", "3"}},
+		{3, 5, []string{"3", "4", "5"}},
+		{3, 7, []string{"5", "6", "7"}},
+		{0, 2, nil},
+	}
+
+	for _, test := range tests {
+
+		ring := NewRingSink(test.capacity)
+
+		for i := 1; i <= test.writes; i++ {
+			ring.Write(Entry{Message: fmt.Sprint(i)})
+		}
+
+		var got []string
+
+		for _, entry := range ring.Entries() {
+			got = append(got, entry.Message)
+		}
+
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("ring of %d after %d writes holds %v, want %v", test.capacity, test.writes, got, test.want)
+		}
+
+	}
+
+}
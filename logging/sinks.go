@@ -0,0 +1,181 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/* -- [[ Sinks ]] -- */
+
+// Sinks are called with the logging lock held, so entries arrive one at a time and in order
+type Sink interface {
+	Write(entry Entry)
+}
+
+/* -- [[ Writer ]] -- */
+
+type WriterSink struct {
+	Writer io.Writer
+}
+
+func NewWriterSink(writer io.Writer) *WriterSink {
+	return &WriterSink{Writer: writer}
+}
+
+func (s *WriterSink) Write(entry Entry) {
+	fmt.Fprintln(s.Writer, entry)
+}
+
+/* -- [[ Rotating File ]] -- */
+
+const (
+	DefaultMaxFileSize = 4 << 20
+	DefaultMaxBackups  = 3
+)
+
+// RotatingFileSink appends to a file and moves it to file.1 once it grows past MaxSize,
+// shifting older backups up and dropping the one past MaxBackups
+type RotatingFileSink struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	file *os.File
+	size int64
+}
+
+func NewRotatingFileSink(path string, maxSize int64, maxBackups int) (*RotatingFileSink, error) {
+
+	sink := &RotatingFileSink{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+
+}
+
+func (s *RotatingFileSink) open() error {
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = info.Size()
+
+	return nil
+
+}
+
+func (s *RotatingFileSink) rotate() error {
+
+	s.file.Close()
+	s.file = nil
+
+	for i := s.MaxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%v.%d", s.Path, i), fmt.Sprintf("%v.%d", s.Path, i+1))
+	}
+
+	if s.MaxBackups > 0 {
+		os.Rename(s.Path, s.Path+".1")
+	} else {
+		os.Remove(s.Path)
+	}
+
+	return s.open()
+
+}
+
+func (s *RotatingFileSink) Write(entry Entry) {
+
+	if s.file == nil {
+		return
+	}
+
+	line := entry.String() + "\n"
+
+	if s.MaxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.MaxSize {
+
+		if err := s.rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, "Log file rotation failed:", err)
+			return
+		}
+
+	}
+
+	written, _ := io.WriteString(s.file, line)
+	s.size += int64(written)
+
+}
+
+func (s *RotatingFileSink) Close() error {
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+
+}
+
+/* -- [[ Ring Buffer ]] -- */
+
+// RingSink keeps the latest entries in memory, for showing them in game
+type RingSink struct {
+	entries []Entry
+	next    int
+	count   int
+}
+
+func NewRingSink(capacity int) *RingSink {
+	return &RingSink{entries: make([]Entry, capacity)}
+}
+
+func (s *RingSink) Write(entry Entry) {
+
+	if len(s.entries) == 0 {
+		return
+	}
+
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % len(s.entries)
+	s.count = min(s.count+1, len(s.entries))
+
+}
+
+// Entries returns the kept entries, oldest first
+func (s *RingSink) Entries() []Entry {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	output := make([]Entry, 0, s.count)
+	start := (s.next - s.count + len(s.entries)) % max(len(s.entries), 1)
+
+	for i := 0; i < s.count; i++ {
+		output = append(output, s.entries[(start+i)%len(s.entries)])
+	}
+
+	return output
+
+}
@@ -1,72 +1,150 @@
 package main
 
 import (
-	"fmt"
+	"errors"
+	"os"
 	"runtime"
 
 	ClientContext "VoxelRPG/client"
 	Log "VoxelRPG/logging"
+	Profiling "VoxelRPG/profiling"
 	Types "VoxelRPG/types"
+	World "VoxelRPG/world"
 
 	"github.com/go-gl/gl/v4.6-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
-
-	_ "net/http/pprof"
 )
 
+// Set VOXELRPG_TRACE to a file to write a Chrome trace of the whole session to it on exit
+var TraceFile = os.Getenv("VOXELRPG_TRACE")
+
 func main() {
+	os.Exit(run())
+}
+
+// run returns the exit code, so deferred cleanup still happens on failures
+func run() int {
 
 	runtime.LockOSThread()
 
+	defer Log.Close()
+
+	if TraceFile != "" {
+		Profiling.StartTrace()
+	}
+
 	WindowBuilder := &Types.WindowBuilder{
 		Width:  800,
 		Height: 600,
 		Title:  "Voxel RPG",
 	}
 
+	WindowBuilder.ApplyEnv()
+	Types.PostConfig.ApplyEnv()
+	Types.PostProcessing.ApplyEnv()
+	Types.Recording.ApplyEnv()
+
 	window, err := Types.CreateWindow(WindowBuilder)
-	Types.CheckError(err)
 
-	go renderLoop(window)
+	if err != nil {
+		Log.Main.Error("Could not start", "error", &Types.SetupError{Stage: "window", Err: err})
+		return 1
+	}
+
+	defer glfw.Terminate()
+
+	rendering := make(chan error, 1)
+
+	go func() {
+
+		err := renderLoop(window, WindowBuilder)
+
+		// Wake the event loop below so a failed render loop closes the window
+
+		window.SetShouldClose(true)
+		glfw.PostEmptyEvent()
+
+		rendering <- err
+
+	}()
 
 	for !window.ShouldClose() {
 		// WaitEventsTimeout waits max 100ms or until an event happens,
 		// so the UI remains responsive during resize.
 		glfw.WaitEventsTimeout(0.1)
+		Types.RunWindowTasks()
+	}
+
+	// Closing during startup abandons world generation instead of waiting for it
+
+	World.MainWorld.StopGeneration()
+
+	// Let the render loop end any recording and screenshots still being encoded finish writing
+
+	err = <-rendering
+	Types.Screenshots.Wait()
+
+	if TraceFile != "" {
+
+		if err := Profiling.StopTrace(TraceFile); err != nil {
+			Log.Main.Error("Could not write the trace", "file", TraceFile, "error", err)
+		} else {
+			Log.Main.Info("Trace written", "file", TraceFile)
+		}
+
 	}
 
-	glfw.Terminate()
+	if errors.Is(err, World.ErrGenerationStopped) {
+		Log.Main.Info("Closed during world generation")
+		return 0
+	}
+
+	if err != nil {
+		Log.Main.Error("Stopped after an error", "error", err)
+		return 1
+	}
+
+	return 0
 
 }
 
-func renderLoop(window *glfw.Window) {
+func renderLoop(window *glfw.Window, WindowBuilder *Types.WindowBuilder) error {
 
 	runtime.LockOSThread()
 	window.MakeContextCurrent()
-	glfw.SwapInterval(1)
+	Types.SetVSync(WindowBuilder.VSync)
 
-	W, H := window.GetSize()
+	// From here on the builder holds the framebuffer size, which is larger on scaled displays
 
-	WindowBuilder := &Types.WindowBuilder{
-		Width:  W,
-		Height: H,
-		Title:  "",
-	}
+	WindowBuilder.Width, WindowBuilder.Height = window.GetFramebufferSize()
 
-	err := Types.NewGLContext()
-	Types.CheckError(err)
+	if err := Types.NewGLContext(); err != nil {
+		return &Types.SetupError{Stage: "OpenGL context", Err: err}
+	}
 
 	Client, err := ClientContext.NewClient()
-	Types.CheckError(err)
 
-	Types.OpenGLSetup(WindowBuilder, Client)
+	if err != nil {
+		return &Types.SetupError{Stage: "client", Err: err}
+	}
+
+	// Deletes whatever setup managed to create, also when it failed part way
+
+	defer Types.OpenGLShutdown()
+
+	if err := Types.OpenGLSetup(WindowBuilder, Client); err != nil {
+		return err
+	}
 
 	version := gl.GoStr(gl.GetString(gl.VERSION))
-	Log.NewLog("Loaded OpenGL - Version:", version, "\n")
+	Log.GL.Info("Loaded OpenGL", "version", version)
 
-	Log.NewLog("Events - Initializing..")
+	Log.Client.Info("Events - Initializing..")
 
 	Client.SetupKeybinds()
+	Types.SetupCaptureKeybinds(Client)
+	Types.SetupDebugKeybinds(Client)
+	Types.SetupWindowKeybinds(Client, window, WindowBuilder)
 
 	window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 		Types.WindowInputCB(Client, w, key, scancode, action, mods)
@@ -80,7 +158,13 @@ func renderLoop(window *glfw.Window) {
 		Types.WindowMouseCB(Client, w, xpos, ypos)
 	})
 
-	Log.NewLog("Events - Initialized\n")
+	Log.Client.Info("Events - Initialized")
+
+	if err := Types.DebugServer.Start(Client); err != nil {
+		Log.Main.Error("Debug server not started", "error", err)
+	}
+
+	defer Types.DebugServer.Close()
 
 	if glfw.RawMouseMotionSupported() {
 		window.SetInputMode(glfw.RawMouseMotion, glfw.True)
@@ -94,7 +178,7 @@ func renderLoop(window *glfw.Window) {
 
 	})
 
-	Log.NewLog("Program startup:")
+	Log.Main.Info("Program startup")
 
 	CheckDelta := float32(1) / float32(165)
 	UpdateCheck := float64(0.0)
@@ -108,6 +192,9 @@ func renderLoop(window *glfw.Window) {
 		Delta := Now - LastCh
 		LastCh = Now
 
+		Types.FrameTimes.Add(Delta * 1000)
+		Types.DebugServer.Poll()
+
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
 		if Now > UpdateCheck {
@@ -117,7 +204,7 @@ func renderLoop(window *glfw.Window) {
 			Types.OpenGLFixedUpdate(window, WindowBuilder)
 			ClientContext.ClientCheckMovement(Client, float32(1.0)/float32(60.0))
 
-			fmt.Println("FPS:", 1/Delta)
+			Types.GPUProfiler.ReportIfDue(Now)
 
 		}
 
@@ -128,4 +215,8 @@ func renderLoop(window *glfw.Window) {
 
 	}
 
+	Types.Recording.Close()
+
+	return nil
+
 }
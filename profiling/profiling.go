@@ -0,0 +1,344 @@
+package profiling
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	Log "VoxelRPG/logging"
+)
+
+/* -- [[ CPU Profiler ]] -- */
+
+// Scopes are named and nest per goroutine, a scope started while another is open on the same
+// goroutine becomes its child. Every finished scope is added to the totals under its full path
+// ("world.generate/chunk.octree"), and while a trace is running it is also kept as an event for
+// a Chrome tracing JSON that chrome://tracing or Perfetto can open.
+//
+//	defer Profiling.Start("world.generate").End()
+
+const MaxTraceEvents = 1 << 20 // Events kept per trace, later scopes are only counted
+
+type Scope struct {
+	Name string
+	Path string
+
+	start     time.Time
+	goroutine uint64
+}
+
+type Stats struct {
+	Path  string
+	Count int
+
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+type traceEvent struct {
+	Name  string  `json:"name"`
+	Cat   string  `json:"cat"`
+	Phase string  `json:"ph"`
+	Time  float64 `json:"ts"`  // Microseconds since the trace started
+	Dur   float64 `json:"dur"` // Microseconds
+	PID   int     `json:"pid"`
+	TID   uint64  `json:"tid"`
+
+	Args map[string]string `json:"args,omitempty"`
+}
+
+var (
+	Enabled = true
+
+	mutex sync.Mutex
+	open  = map[uint64][]*Scope{}
+	stats = map[string]*Stats{}
+
+	tracing    bool
+	traceStart time.Time
+	events     []traceEvent
+	dropped    int
+)
+
+// goroutineID reads the id from the header of the goroutine's stack trace, Go has no other way
+// to tell goroutines apart. It costs about a microsecond, so scopes belong around real work only.
+func goroutineID() uint64 {
+
+	var buffer [64]byte
+
+	return parseGoroutineID(buffer[:runtime.Stack(buffer[:], false)])
+
+}
+
+// parseGoroutineID reads the id from a "goroutine 12 [running]:" header, 0 if there is none
+func parseGoroutineID(header []byte) uint64 {
+
+	header = bytes.TrimPrefix(header, []byte("goroutine "))
+
+	if end := bytes.IndexByte(header, ' '); end > 0 {
+		header = header[:end]
+	}
+
+	id, _ := strconv.ParseUint(string(header), 10, 64)
+
+	return id
+
+}
+
+// Start opens a scope on the calling goroutine, it must be ended on the same goroutine
+func Start(name string) *Scope {
+
+	if !Enabled {
+		return nil
+	}
+
+	scope := &Scope{Name: name, Path: name, goroutine: goroutineID()}
+
+	mutex.Lock()
+
+	stack := open[scope.goroutine]
+
+	if len(stack) > 0 {
+		scope.Path = stack[len(stack)-1].Path + "/" + name
+	}
+
+	open[scope.goroutine] = append(stack, scope)
+
+	mutex.Unlock()
+
+	scope.start = time.Now()
+
+	return scope
+
+}
+
+// End closes the scope and returns how long it was open. Scopes opened inside it and not yet
+// ended are closed with it.
+func (s *Scope) End() time.Duration {
+
+	if s == nil {
+		return 0
+	}
+
+	elapsed := time.Since(s.start)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	stack := open[s.goroutine]
+
+	for i := len(stack) - 1; i >= 0; i-- {
+
+		if stack[i] == s {
+			stack = stack[:i]
+			break
+		}
+
+	}
+
+	if len(stack) == 0 {
+		delete(open, s.goroutine)
+	} else {
+		open[s.goroutine] = stack
+	}
+
+	total := stats[s.Path]
+
+	if total == nil {
+		total = &Stats{Path: s.Path, Min: elapsed}
+		stats[s.Path] = total
+	}
+
+	total.Count++
+	total.Total += elapsed
+	total.Min = min(total.Min, elapsed)
+	total.Max = max(total.Max, elapsed)
+
+	if tracing {
+		s.record(elapsed)
+	}
+
+	return elapsed
+
+}
+
+func (s *Scope) record(elapsed time.Duration) {
+
+	if len(events) >= MaxTraceEvents {
+		dropped++
+		return
+	}
+
+	category := s.Name
+
+	if dot := strings.IndexByte(category, '.'); dot > 0 {
+		category = category[:dot]
+	}
+
+	events = append(events, traceEvent{
+		Name:  s.Name,
+		Cat:   category,
+		Phase: "X",
+		Time:  float64(s.start.Sub(traceStart).Nanoseconds()) / 1e3,
+		Dur:   float64(elapsed.Nanoseconds()) / 1e3,
+		PID:   1,
+		TID:   s.goroutine,
+	})
+
+}
+
+/* -- [[ Aggregated Totals ]] -- */
+
+func (s Stats) Avg() time.Duration {
+
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.Total / time.Duration(s.Count)
+
+}
+
+// Totals returns the statistics of every scope path, sorted so children follow their parent
+func Totals() []Stats {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	output := make([]Stats, 0, len(stats))
+
+	for _, total := range stats {
+		output = append(output, *total)
+	}
+
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Path < output[j].Path
+	})
+
+	return output
+
+}
+
+func Reset() {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	clear(stats)
+
+}
+
+// Report lists the totals as an indented tree
+func Report() string {
+
+	var report strings.Builder
+
+	report.WriteString("CPU profile:")
+
+	for _, total := range Totals() {
+
+		depth := strings.Count(total.Path, "/")
+		name := total.Path[strings.LastIndexByte(total.Path, '/')+1:]
+
+		fmt.Fprintf(&report, "\n  %-32v %6d calls, total %v, avg %v, max %v",
+			strings.Repeat("  ", depth)+name, total.Count, total.Total.Round(time.Microsecond), total.Avg().Round(time.Microsecond), total.Max.Round(time.Microsecond))
+
+	}
+
+	return report.String()
+
+}
+
+/* -- [[ Chrome Tracing ]] -- */
+
+// StartTrace begins keeping events for a trace, discarding any from an earlier one
+func StartTrace() {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	tracing = true
+	traceStart = time.Now()
+	events = events[:0]
+	dropped = 0
+
+}
+
+func Tracing() bool {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return tracing
+
+}
+
+// StopTrace ends the trace and writes it to a file in the Chrome tracing JSON format
+func StopTrace(file string) error {
+
+	mutex.Lock()
+
+	tracing = false
+
+	trace := struct {
+		Events []traceEvent `json:"traceEvents"`
+		Unit   string       `json:"displayTimeUnit"`
+	}{
+		Events: make([]traceEvent, 0, len(events)),
+		Unit:   "ms",
+	}
+
+	// Name the goroutines so the viewer shows more than bare ids
+
+	threads := map[uint64]bool{}
+
+	for _, event := range events {
+		threads[event.TID] = true
+	}
+
+	for id := range threads {
+		trace.Events = append(trace.Events, traceEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			PID:   1,
+			TID:   id,
+			Args:  map[string]string{"name": "goroutine " + strconv.FormatUint(id, 10)},
+		})
+	}
+
+	trace.Events = append(trace.Events, events...)
+	skipped := dropped
+
+	events = nil
+	dropped = 0
+
+	mutex.Unlock()
+
+	if skipped > 0 {
+		Log.Main.Warn("Trace was full, later scopes were left out", "dropped", skipped)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	output, err := os.Create(file)
+
+	if err != nil {
+		return err
+	}
+
+	defer output.Close()
+
+	return json.NewEncoder(output).Encode(trace)
+
+}
@@ -0,0 +1,264 @@
+package profiling
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// resetProfiler starts a test with profiling enabled and no totals, trace or open scopes
+func resetProfiler(t *testing.T) {
+
+	enabled := Enabled
+
+	clean := func() {
+
+		Reset()
+
+		mutex.Lock()
+		clear(open)
+		tracing, events, dropped = false, nil, 0
+		mutex.Unlock()
+
+	}
+
+	t.Cleanup(func() {
+		clean()
+		Enabled = enabled
+	})
+
+	clean()
+	Enabled = true
+
+}
+
+// onGoroutine runs f on a new goroutine and waits for it
+func onGoroutine(f func()) {
+
+	var done sync.WaitGroup
+	done.Add(1)
+
+	go func() {
+		defer done.Done()
+		f()
+	}()
+
+	done.Wait()
+
+}
+
+func TestNestedScopePaths(t *testing.T) {
+
+	resetProfiler(t)
+
+	outer := Start("world.generate")
+	inner := Start("chunk.octree")
+
+	if inner.Path != "world.generate/chunk.octree" {
+		t.Errorf("nested scope path %q, want world.generate/chunk.octree", inner.Path)
+	}
+
+	inner.End()
+
+	if sibling := Start("chunk.upload"); sibling.Path != "world.generate/chunk.upload" {
+		t.Errorf("sibling scope path %q, want world.generate/chunk.upload", sibling.Path)
+	} else {
+		sibling.End()
+	}
+
+	// Other goroutines have their own stacks
+
+	onGoroutine(func() {
+
+		if worker := Start("chunk.generate"); worker.Path != "chunk.generate" {
+			t.Errorf("scope on another goroutine has path %q, want chunk.generate", worker.Path)
+		} else {
+			worker.End()
+		}
+
+	})
+
+	// Ending a scope closes the scopes still open inside it
+
+	Start("chunk.bake")
+	outer.End()
+
+	if after := Start("chunk.octree"); after.Path != "chunk.octree" {
+		t.Errorf("scope after the outer one ended has path %q, want chunk.octree", after.Path)
+	} else {
+		after.End()
+	}
+
+	if len(open) != 0 {
+		t.Errorf("%d goroutines still have open scopes", len(open))
+	}
+
+}
+
+func TestTotals(t *testing.T) {
+
+	resetProfiler(t)
+
+	for i := 0; i < 3; i++ {
+
+		outer := Start("b")
+		Start("a").End()
+		outer.End()
+
+	}
+
+	Start("a").End()
+
+	totals := Totals()
+
+	paths := []string{"a", "b", "b/a"}
+	counts := []int{1, 3, 3}
+
+	if len(totals) != len(paths) {
+		t.Fatalf("totals %+v, want paths %v", totals, paths)
+	}
+
+	for i, total := range totals {
+
+		if total.Path != paths[i] || total.Count != counts[i] {
+			t.Errorf("total %d is %v with %d calls, want %v with %d", i, total.Path, total.Count, paths[i], counts[i])
+		}
+
+		if total.Min > total.Avg() || total.Avg() > total.Max || total.Total < total.Max {
+			t.Errorf("%v: min %v, avg %v, max %v and total %v are out of order", total.Path, total.Min, total.Avg(), total.Max, total.Total)
+		}
+
+	}
+
+	Reset()
+
+	if totals := Totals(); len(totals) != 0 {
+		t.Errorf("totals after Reset %+v, want none", totals)
+	}
+
+}
+
+func TestStopTraceWritesChromeJSON(t *testing.T) {
+
+	resetProfiler(t)
+
+	Start("before.trace").End()
+
+	StartTrace()
+
+	if !Tracing() {
+		t.Fatal("Tracing is false after StartTrace")
+	}
+
+	outer := Start("world.load")
+	Start("chunk.upload").End()
+	outer.End()
+
+	onGoroutine(func() {
+		Start("chunk.generate").End()
+	})
+
+	file := filepath.Join(t.TempDir(), "traces", "trace.json")
+
+	if err := StopTrace(file); err != nil {
+		t.Fatal(err)
+	}
+
+	Start("after.trace").End()
+
+	if Tracing() {
+		t.Error("Tracing is true after StopTrace")
+	}
+
+	data, err := os.ReadFile(file)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var trace struct {
+		Events []traceEvent `json:"traceEvents"`
+		Unit   string       `json:"displayTimeUnit"`
+	}
+
+	if err := json.Unmarshal(data, &trace); err != nil {
+		t.Fatalf("trace is not valid JSON: %v", err)
+	}
+
+	scopes := map[string]traceEvent{}
+	threads := map[uint64]string{}
+
+	for _, event := range trace.Events {
+
+		switch event.Phase {
+		case "X":
+			scopes[event.Name] = event
+		case "M":
+			threads[event.TID] = event.Args["name"]
+		default:
+			t.Errorf("event %+v has an unknown phase", event)
+		}
+
+	}
+
+	categories := map[string]string{"world.load": "world", "chunk.upload": "chunk", "chunk.generate": "chunk"}
+
+	if len(scopes) != len(categories) {
+		t.Errorf("trace has scopes %v, want %v", scopes, categories)
+	}
+
+	for name, category := range categories {
+
+		event, ok := scopes[name]
+
+		if !ok || event.Cat != category || event.Time < 0 || event.Dur < 0 || threads[event.TID] == "" {
+			t.Errorf("%v: event %+v, want category %v on a named thread", name, event, category)
+		}
+
+	}
+
+	if len(threads) != 2 || scopes["world.load"].TID == scopes["chunk.generate"].TID {
+		t.Errorf("trace names threads %v, want the two goroutines", threads)
+	}
+
+	if trace.Unit != "ms" {
+		t.Errorf("display time unit %q, want ms", trace.Unit)
+	}
+
+}
+
+func TestParseGoroutineID(t *testing.T) {
+
+	tests := []struct {
+		header string
+		want   uint64
+	}{
+		{"goroutine 1 [running]:\nmain.main()", 1},
+		{"goroutine 4821 [chan receive]:", 4821},
+		{"goroutine 18446744073709551615 [running]:", 18446744073709551615},
+		{"goroutine x [running]:", 0},
+		{"", 0},
+	}
+
+	for _, test := range tests {
+		if got := parseGoroutineID([]byte(test.header)); got != test.want {
+			t.Errorf("parseGoroutineID(%q) = %d, want %d", test.header, got, test.want)
+		}
+	}
+
+	// The real stack header parses to a different id on every goroutine
+
+	main := goroutineID()
+	var other uint64
+
+	onGoroutine(func() {
+		other = goroutineID()
+	})
+
+	if main == 0 || other == 0 || main == other {
+		t.Errorf("goroutine ids %d and %d, want two different non zero ids", main, other)
+	}
+
+}
@@ -0,0 +1,8 @@
+package shaders
+
+import "embed"
+
+// Files holds every shader, so the binary runs from any working directory
+//
+//go:embed *.vert *.frag include/*.glsl
+var Files embed.FS
@@ -0,0 +1,54 @@
+// Code generated by tools/glslgen from the world package. DO NOT EDIT.
+
+const int BINDING_NODES = 0;
+const int BINDING_CHUNK_INFO = 1;
+const int BINDING_DISPLACEMENTS = 2;
+const int BINDING_DEBUG = 3;
+const int BINDING_MATERIALS = 4;
+const int BINDING_LIGHTS = 5;
+const uint HASH_MIX_A = 0x7FEB352Du;
+const uint HASH_MIX_B = 0x846CA68Bu;
+const uint HASH_GOLDEN = 0x9E3779B9u;
+const uint HASH_SIGN_OFFSET = 0x80000000u;
+const ivec3 HASH2_MULTIPLIER = ivec3(0x27D4EB2D, 0x165667B1, 0x1B873593);
+
+// GridMetadata, 16 bytes
+struct GridMetadata {
+    uint R;
+    uint G;
+    uint B;
+    uint material;
+};
+
+// GridNodeFlatGPU, 64 bytes
+struct GridNodeFlat {
+    uint children[8];
+    uint flags;
+    int size;
+    uint light[2];
+    GridMetadata metadata;
+};
+
+// MapEntry, 16 bytes
+struct ChunkInfo {
+    ivec3 Position;
+    uint offset;
+};
+
+// MaterialGPU, 16 bytes
+struct Material {
+    uint flags;
+    float opacity;
+    float ior;
+    float emission;
+};
+
+// LightGPU, 48 bytes
+struct Light {
+    vec3 position;
+    uint type;
+    vec3 color;
+    float range;
+    vec3 direction;
+    float spotCos;
+};
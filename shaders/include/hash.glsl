@@ -0,0 +1,33 @@
+// Must match the CPU hashes in world/types.go exactly, the constants are generated from them
+
+#include "gpu_types.glsl"
+
+uint hash_u32(uint x) {
+    x ^= x >> 16u;
+    x *= HASH_MIX_A;
+    x ^= x >> 15u;
+    x *= HASH_MIX_B;
+    x ^= x >> 16u;
+    return x;
+}
+
+uint hash3D(ivec3 v) {
+    // Convert signed to unsigned (e.g. offset to positive range)
+    uvec3 uv = uvec3(v) + uvec3(HASH_SIGN_OFFSET);
+
+    // Mix the three components into one uint hash
+    uint h = hash_u32(uv.x);
+    h ^= hash_u32(uv.y) + HASH_GOLDEN + (h << 6u) + (h >> 2u);
+    h ^= hash_u32(uv.z) + HASH_GOLDEN + (h << 6u) + (h >> 2u);
+
+    return h;
+}
+
+// Example hash functions - MUST match your CPU ones exactly
+uint hash1(ivec3 pos) {
+    return hash3D(pos);
+}
+
+uint hash2(ivec3 pos) {
+    return hash3D(pos * HASH2_MULTIPLIER);
+}
@@ -0,0 +1,17 @@
+// Node structs are generated from the Go types, see world/gpulayout.go
+
+#include "gpu_types.glsl"
+
+/* -- [[ Octree Leaf Decoding ]] -- */
+
+struct FlagBits {
+    bool occupied;
+    bool leaf;
+};
+
+FlagBits DecodeFlags(uint flags) {
+    FlagBits result;
+    result.occupied = (flags & FLAG_OCCUPIED) != 0u;
+    result.leaf = (flags & FLAG_LEAF) != 0u;
+    return result;
+}
@@ -6,115 +6,122 @@ in vec2 fragTexCoord;
 
 /* -- [[ Shader Outputs ]] -- */
 
-out vec4 FragColor;
+layout(location = 0) out vec4 FragColor;
+layout(location = 1) out float FragDepth; // Distance to the first surface, read by the temporal pass
 
 /* -- [[ Octree Traversal Grid SSBO ]] -- */
 
-struct GridMetadata {
-    uint R;
-    uint G;
-    uint B;
-    uint padding;
-};
-
-struct GridNodeFlat {
-    uint[8] children;
-    uint flags;
-    int size;
-    uint padding[2];
-    GridMetadata metadata;
-};
+#include "include/octree.glsl"
 
-
-layout(std430, binding = 0) buffer NodeBuffer {
+layout(std430, binding = BINDING_NODES) buffer NodeBuffer {
     GridNodeFlat nodes[];
 };
 
 /* -- [[ Chunk Info & Hashmap Offsets SSBO ]] -- */
 
-struct ChunkInfo {
-    ivec3 Position;
-    uint offset;
-};
-
-layout(std430, binding = 1) buffer ChunkInfoBuffer {
+layout(std430, binding = BINDING_CHUNK_INFO) buffer ChunkInfoBuffer {
     ChunkInfo chunkInfo[];
 };
 
-layout(std430, binding = 2) buffer Offsets {
+layout(std430, binding = BINDING_DISPLACEMENTS) buffer Offsets {
     uint displacements[];
 };
 
-/*layout(std430, binding = 3) buffer DebugResult {
+/* -- [[ Material SSBO ]] -- */
+
+layout(std430, binding = BINDING_MATERIALS) buffer MaterialBuffer {
+    Material materials[];
+};
+
+/* -- [[ Light SSBO ]] -- */
+
+layout(std430, binding = BINDING_LIGHTS) buffer LightBuffer {
+    Light lights[];
+};
+
+const int MAX_LIGHTS_PER_HIT = 8;
+
+uniform uint numLights;
+uniform int maxLightsPerHit;
+
+/*layout(std430, binding = BINDING_DEBUG) buffer DebugResult {
     vec3 debugOutput;
 };*/
 
 /* -- [[ World Variables ]] -- */
 
-uniform uint numChunks;
 uniform float chunkSize;
 uniform float chunkScale;
 
 /* -- [[ Shader Variables ]] -- */
 
 uniform vec2 iResolution;
+uniform float iTime;
 uniform float fov;
 uniform vec3 camPos;
+uniform int maxTransparencyDepth;
 uniform mat4 invView;
+uniform vec2 jitter; // Sub-pixel camera offset for temporal anti-aliasing, in pixels
+uniform vec4 viewport; // Part of the image this draw covers as offset then size, smaller than the whole for tiled captures
+uniform int debugView;
 
 float resolutionScale = iResolution.y / tan(fov / 2.0);
 
-/* -- [[ Hashmap Variables ]] -- */
+/* -- [[ Lighting Variables ]] -- */
 
-uniform int numBuckets;
+uniform vec3 sunDirection;
+uniform vec3 sunColor;
+uniform vec3 ambientColor;
 
-/* -- [[ Grid Map Variables ]] -- */
+uniform int lightingMode;
+uniform vec3 blockLightColor;
 
-uint MAX_STEPS = 24;
+uniform int shadowSamples;
+uniform float shadowSoftness;
 
-struct FaceHit {
-    vec3 normal;
-    vec3 position;
-};
+uniform int aoQuality;
+uniform float aoStrength;
 
-/* -- [[ Global Variables ]] -- */
+/* -- [[ Sky & Fog Variables ]] -- */
 
-uint MaxUINT32 = 0xFFFFFFFFu;
-float EPSILON = 1e-4;
+uniform vec3 skyZenithColor;
+uniform vec3 skyHorizonColor;
+uniform vec3 skyGroundColor;
+uniform float sunDiscCos;
+uniform float sunDiscIntensity;
 
-/* -- [[ Hashmap Functions ]] -- */
+uniform vec3 fogColor;
+uniform float fogDensity;
+uniform float fogStart;
 
-uint hash_u32(uint x) {
-    x ^= x >> 16u;
-    x *= 0x7feb352du;
-    x ^= x >> 15u;
-    x *= 0x846ca68bu;
-    x ^= x >> 16u;
-    return x;
-}
+/* -- [[ Grid Map Variables ]] -- */
 
-uint hash3D(ivec3 v) {
-    // Convert signed to unsigned (e.g. offset to positive range)
-    uvec3 uv = uvec3(v) + uvec3(0x80000000u);
+struct FaceHit {
+    vec3 normal;
+    vec3 position;
+};
 
-    // Mix the three components into one uint hash
-    uint h = hash_u32(uv.x);
-    h ^= hash_u32(uv.y) + 0x9e3779b9u + (h << 6u) + (h >> 2u);
-    h ^= hash_u32(uv.z) + 0x9e3779b9u + (h << 6u) + (h >> 2u);
+struct RayHit {
+    vec4 color;
+    vec3 position;
+    vec3 normal;
+    float t;
+    uint material;
+    uvec2 light;
+    vec3 boxMin;
+    vec3 boxMax;
+    bool lodCutoff; // Stopped at an inner node because it was smaller than a pixel
+};
 
-    return h;
-}
+/* -- [[ Global Variables ]] -- */
 
-// Example hash functions - MUST match your CPU ones exactly
-uint hash1(ivec3 pos) {
-    return hash3D(pos);
-}
+float EPSILON = 1e-4;
 
-uint hash2(ivec3 pos) {
-    return hash3D(pos * ivec3(0x27d4eb2du, 0x165667b1u, 0x1b873593u));
-}
+int traversalSteps = 0; // Chunks and octree nodes visited, for the step count debug view
 
+/* -- [[ Hashmap Functions ]] -- */
 
+#include "include/hash.glsl"
 
 ChunkInfo lookupRootOffset(ivec3 chunkPos) {
     int N = int(chunkInfo.length());
@@ -125,12 +132,12 @@ ChunkInfo lookupRootOffset(ivec3 chunkPos) {
     uint idx = (h1Val + displacements[h2Val]) % uint(N);
 
     ChunkInfo result;
-    result.offset = 0xFFFFFFFFu;  // MaxUInt32 default for not found
+    result.offset = MAX_UINT32;  // Default for not found
     result.Position = ivec3(0);
 
     ChunkInfo entry = chunkInfo[idx];
 
-    if (entry.offset == 0xFFFFFFFFu) {
+    if (entry.offset == MAX_UINT32) {
         return result; // Not found
     }
 
@@ -161,23 +168,48 @@ bool intersectAABB(vec3 ro, vec3 rd, vec3 bmin, vec3 bmax, out float tNear, out
     return (tFar >= max(tNear, 0.0));
 }
 
-/* -- [[ Octree Leaf Decoding ]] -- */
+// Normal of the AABB face the ray enters through
+vec3 entryNormalAABB(vec3 ro, vec3 rd, vec3 bmin, vec3 bmax) {
+    vec3 invDir = 1.0 / rd;
+    vec3 tmin = min((bmin - ro) * invDir, (bmax - ro) * invDir);
 
-struct FlagBits {
-    bool occupied;
-    bool leaf;
-};
+    if (tmin.x > tmin.y && tmin.x > tmin.z) {
+        return vec3(-sign(rd.x), 0.0, 0.0);
+    } else if (tmin.y > tmin.z) {
+        return vec3(0.0, -sign(rd.y), 0.0);
+    }
 
-FlagBits DecodeFlags(uint flags) {
-    FlagBits result;
-    result.occupied = (flags & 1u) != 0u;     // bit 0
-    result.leaf = (flags & 2u) != 0u;         // bit 1
-    return result;
+    return vec3(0.0, 0.0, -sign(rd.z));
+}
+
+RayHit newRayHit(vec3 ro, vec3 rd, vec3 bmin, vec3 bmax, GridNodeFlat node) {
+    float tNear, tFar;
+    intersectAABB(ro, rd, bmin, bmax, tNear, tFar);
+
+    RayHit hit;
+    hit.color = vec4(vec3(node.metadata.R, node.metadata.G, node.metadata.B) / 256.0, 1.0);
+    hit.t = max(tNear, 0.0);
+    hit.position = ro + rd * hit.t;
+    hit.normal = entryNormalAABB(ro, rd, bmin, bmax);
+    hit.material = node.metadata.material;
+    hit.light = uvec2(node.light[0], node.light[1]);
+    hit.boxMin = bmin;
+    hit.boxMax = bmax;
+    hit.lodCutoff = false;
+    return hit;
+}
+
+bool isTransparent(uint material) {
+    if (material >= materials.length()) {
+        return false;
+    }
+    return (materials[material].flags & MATERIAL_FLAG_TRANSPARENT) != 0u;
 }
 
 /* -- [[ Octree Traversal Function ]] -- */
 
-bool raymarchOctree(vec3 ro, vec3 rd, ChunkInfo rootNode, out vec4 hitColor ) {
+// skipTransparent treats transparent voxels as empty, used by shadow rays
+bool raymarchOctree(vec3 ro, vec3 rd, ChunkInfo rootNode, bool skipTransparent, out RayHit hit ) {
     
     const int MAX_STACK = 64;
     uint stack[MAX_STACK];
@@ -193,6 +225,7 @@ bool raymarchOctree(vec3 ro, vec3 rd, ChunkInfo rootNode, out vec4 hitColor ) {
     while (stackSize > 0) {
 
         stackSize--;
+        traversalSteps++;
         
         uint nodeIndex = stack[stackSize];
         vec3 nodePos = stackPos[stackSize];
@@ -210,8 +243,15 @@ bool raymarchOctree(vec3 ro, vec3 rd, ChunkInfo rootNode, out vec4 hitColor ) {
         float distance = length(camPos - ((boxMin + boxMax) / 2 ));
         float screenSpaceSize = (voxelSize / distance) * resolutionScale;
 
-        if (screenSpaceSize < 1) {
-            hitColor = vec4(vec3(node.metadata.R, node.metadata.G, node.metadata.B) / 256.0, 1.0);
+        // Never cut off a node containing the ray origin, secondary rays start inside it
+
+        bool containsOrigin = all(greaterThanEqual(ro, boxMin)) && all(lessThan(ro, boxMax));
+
+        bool skipped = skipTransparent && isTransparent(node.metadata.material);
+
+        if (screenSpaceSize < 1 && !containsOrigin && !skipped) {
+            hit = newRayHit(ro, rd, boxMin, boxMax, node);
+            hit.lodCutoff = !DecodeFlags(node.flags).leaf;
             return true;
         }
 
@@ -226,9 +266,13 @@ bool raymarchOctree(vec3 ro, vec3 rd, ChunkInfo rootNode, out vec4 hitColor ) {
         if (flagInfo.leaf) {
             if (flagInfo.occupied) { 
 
+                if (skipped) {
+                    continue;
+                }
+
                 //float v = ( node.metadata.R + node.metadata.G + node.metadata.B ) / ( 3.0 * 256.0 )
 
-                hitColor = vec4(vec3(node.metadata.R, node.metadata.G, node.metadata.B) / 256.0, 1.0);
+                hit = newRayHit(ro, rd, boxMin, boxMax, node);
                 return true; // Hit found!
             }
         }
@@ -251,7 +295,11 @@ bool raymarchOctree(vec3 ro, vec3 rd, ChunkInfo rootNode, out vec4 hitColor ) {
 
             uint cIndex = node.children[i];
 
-            if ( cIndex == MaxUINT32 ) continue;
+            if ( cIndex == MAX_UINT32 ) continue;
+
+            // Child indices are stored relative to the chunk root
+
+            cIndex += rootNode.offset;
 
             GridNodeFlat child = nodes[cIndex];
             
@@ -351,7 +399,7 @@ FaceHit getVoxelFaceHit(vec3 ro, vec3 rd ) {
     return hit;
 }
 
-bool traverseChunks( in vec3 ro, in vec3 rd, out vec4 finalColor ) { 
+bool traverseChunks( in vec3 ro, in vec3 rd, in bool skipTransparent, out RayHit finalHit ) { 
 
     float cScale = chunkSize * chunkScale;
 
@@ -360,15 +408,17 @@ bool traverseChunks( in vec3 ro, in vec3 rd, out vec4 finalColor ) {
 
     for (int i = 0; i < MAX_STEPS; i++) {
 
+        traversalSteps++;
+
         ChunkInfo f = lookupRootOffset( currentChunk );
 
-        if ( f.offset != MaxUINT32 ) {
+        if ( f.offset != MAX_UINT32 ) {
 
             FlagBits flagInfo = DecodeFlags( nodes[f.offset].flags);
             
             if (flagInfo.occupied) { 
                 
-                bool hit = raymarchOctree(ro, rd, f, finalColor);
+                bool hit = raymarchOctree(ro, rd, f, skipTransparent, finalHit);
 
                 if (hit == true) {
                     return true;
@@ -387,29 +437,516 @@ bool traverseChunks( in vec3 ro, in vec3 rd, out vec4 finalColor ) {
     return false;
 }
 
+/* -- [[ Voxel Occupancy ]] -- */
+
+// Walks the octree of the chunk containing the voxel down to its leaf
+bool isVoxelOccupied(ivec3 voxel) {
+
+    int cSize = int(chunkSize);
+
+    ivec3 chunkPos = ivec3(floor(vec3(voxel) / chunkSize));
+    ChunkInfo info = lookupRootOffset(chunkPos);
+
+    if (info.offset == MAX_UINT32) {
+        return false;
+    }
+
+    ivec3 local = voxel - chunkPos * cSize;
+    uint nodeIndex = info.offset;
+
+    for (int extent = cSize / 2; extent > 0; extent /= 2) {
+
+        if (!DecodeFlags(nodes[nodeIndex].flags).occupied) {
+            return false;
+        }
+
+        ivec3 octant = (local / extent) & 1;
+        uint child = nodes[nodeIndex].children[octant.x | (octant.y << 1) | (octant.z << 2)];
+
+        if (child == MAX_UINT32) {
+            return false;
+        }
+
+        nodeIndex = info.offset + child;
+
+    }
+
+    return DecodeFlags(nodes[nodeIndex].flags).occupied;
+}
+
+/* -- [[ Ambient Occlusion ]] -- */
+
+float occupancy(ivec3 voxel) {
+    return isVoxelOccupied(voxel) ? 1.0 : 0.0;
+}
+
+float vertexAO(float side1, float side2, float corner) {
+    if (side1 > 0.0 && side2 > 0.0) {
+        return 0.0;
+    }
+    return (3.0 - (side1 + side2 + corner)) / 3.0;
+}
+
+// Samples the voxels in the layer in front of the hit face, must match world.AmbientOcclusion
+float ambientOcclusion(RayHit hit) {
+
+    if (aoQuality <= AO_OFF) {
+        return 1.0;
+    }
+
+    float voxelSize = chunkScale;
+
+    ivec3 normal = ivec3(hit.normal);
+    ivec3 voxel = ivec3(floor((hit.position - hit.normal * (EPSILON * voxelSize)) / voxelSize));
+    ivec3 front = voxel + normal;
+
+    ivec3 t1 = normal.x != 0 ? ivec3(0, 1, 0) : ivec3(1, 0, 0);
+    ivec3 t2 = normal.z != 0 ? ivec3(0, 1, 0) : ivec3(0, 0, 1);
+
+    float s1n = occupancy(front - t1);
+    float s1p = occupancy(front + t1);
+    float s2n = occupancy(front - t2);
+    float s2p = occupancy(front + t2);
+
+    float ao;
+
+    if (aoQuality == AO_LOW) {
+
+        ao = 1.0 - (s1n + s1p + s2n + s2p) / 4.0;
+
+    } else {
+
+        vec3 local = hit.position / voxelSize - vec3(voxel);
+        float u = clamp(dot(local, vec3(t1)), 0.0, 1.0);
+        float v = clamp(dot(local, vec3(t2)), 0.0, 1.0);
+
+        float c00 = vertexAO(s1n, s2n, occupancy(front - t1 - t2));
+        float c10 = vertexAO(s1p, s2n, occupancy(front + t1 - t2));
+        float c01 = vertexAO(s1n, s2p, occupancy(front - t1 + t2));
+        float c11 = vertexAO(s1p, s2p, occupancy(front + t1 + t2));
+
+        ao = mix(mix(c00, c10, u), mix(c01, c11, u), v);
+
+    }
+
+    return mix(1.0, ao, aoStrength);
+}
+
+/* -- [[ Lighting ]] -- */
+
+float hash12(vec2 p) {
+    vec3 p3 = fract(vec3(p.xyx) * 0.1031);
+    p3 += dot(p3, p3.yzx + 33.33);
+    return fract((p3.x + p3.y) * p3.z);
+}
+
+vec3 jitterDirection(vec3 dir, float radius, int i) {
+    vec2 seed = gl_FragCoord.xy + vec2(float(i) * 17.0, fract(iTime) * 131.0);
+
+    vec3 offset = vec3(
+        hash12(seed),
+        hash12(seed + 5.3),
+        hash12(seed + 11.7)
+    ) * 2.0 - 1.0;
+
+    return normalize(dir + offset * radius);
+}
+
+float sunShadow(vec3 position, vec3 normal) {
+
+    if (shadowSamples <= 0) {
+        return 1.0;
+    }
+
+    vec3 origin = position + normal * (EPSILON * chunkSize * chunkScale);
+
+    int occluded = 0;
+
+    for (int i = 0; i < shadowSamples; i++) {
+
+        vec3 dir = sunDirection;
+
+        if (shadowSamples > 1) {
+            dir = jitterDirection(sunDirection, shadowSoftness, i);
+        }
+
+        RayHit shadowHit;
+
+        if (traverseChunks(origin, dir, true, shadowHit)) {
+            occluded++;
+        }
+
+    }
+
+    return 1.0 - float(occluded) / float(shadowSamples);
+}
+
+/* -- [[ Point & Spot Lights ]] -- */
+
+// Picks the closest lights in range of the hit, sorted by distance
+int nearestLights(vec3 position, out int selected[MAX_LIGHTS_PER_HIT]) {
+
+    int count = 0;
+    int limit = min(maxLightsPerHit, MAX_LIGHTS_PER_HIT);
+    float distances[MAX_LIGHTS_PER_HIT];
+
+    for (uint i = 0u; i < numLights; i++) {
+
+        vec3 toLight = lights[i].position - position;
+        float distSq = dot(toLight, toLight);
+
+        if (distSq > lights[i].range * lights[i].range) {
+            continue;
+        }
+
+        // Insertion into the sorted list, dropping the furthest once full
+
+        int slot = count < limit ? count : limit;
+
+        while (slot > 0 && distances[slot - 1] > distSq) {
+            if (slot < limit) {
+                distances[slot] = distances[slot - 1];
+                selected[slot] = selected[slot - 1];
+            }
+            slot--;
+        }
+
+        if (slot < limit) {
+            distances[slot] = distSq;
+            selected[slot] = int(i);
+            count = min(count + 1, limit);
+        }
+
+    }
+
+    return count;
+}
+
+vec3 pointLighting(RayHit hit) {
+
+    if (numLights == 0u || maxLightsPerHit <= 0) {
+        return vec3(0.0);
+    }
+
+    int selected[MAX_LIGHTS_PER_HIT];
+    int count = nearestLights(hit.position, selected);
+
+    vec3 origin = hit.position + hit.normal * (EPSILON * chunkSize * chunkScale);
+    vec3 result = vec3(0.0);
+
+    for (int i = 0; i < count; i++) {
+
+        Light light = lights[selected[i]];
+
+        vec3 toLight = light.position - hit.position;
+        float dist = length(toLight);
+        vec3 dir = toLight / max(dist, EPSILON);
+
+        float diffuse = max(dot(hit.normal, dir), 0.0);
+
+        if (diffuse <= 0.0) {
+            continue;
+        }
+
+        float falloff = clamp(1.0 - (dist * dist) / (light.range * light.range), 0.0, 1.0);
+        float attenuation = falloff * falloff;
+
+        if (light.type == LIGHT_SPOT) {
+            attenuation *= smoothstep(light.spotCos, light.spotCos + 0.05, dot(-dir, light.direction));
+        }
+
+        if (attenuation <= 0.0) {
+            continue;
+        }
+
+        // Lights sit inside their emissive voxel, so hits within a voxel of it are not occluders
+
+        RayHit shadowHit;
+
+        if (traverseChunks(origin, dir, true, shadowHit) && shadowHit.t < dist - chunkScale) {
+            continue;
+        }
+
+        result += light.color * diffuse * attenuation;
+
+    }
+
+    return result;
+}
+
+/* -- [[ Flood Fill Lighting ]] -- */
+
+// Sky and block light levels (0-15) baked for the face that was hit, see world.BakeLight
+vec2 bakedLight(RayHit hit) {
+
+    ivec3 n = ivec3(hit.normal);
+
+    int face = 5;
+
+    if (n.x != 0) {
+        face = n.x < 0 ? 0 : 1;
+    } else if (n.y != 0) {
+        face = n.y < 0 ? 2 : 3;
+    } else if (n.z < 0) {
+        face = 4;
+    }
+
+    uint faceLight = (hit.light[face / 4] >> uint((face % 4) * 8)) & 0xFFu;
+
+    return vec2(float(faceLight >> 4u), float(faceLight & 0xFu));
+}
+
+float lightLevelBrightness(float level) {
+    return level > 0.0 ? pow(0.8, 15.0 - level) : 0.0;
+}
+
+vec3 shade(RayHit hit) {
+
+    float diffuse = max(dot(hit.normal, sunDirection), 0.0);
+    vec3 light;
+
+    if (lightingMode == LIGHTING_FLOOD_FILL) {
+
+        vec2 levels = bakedLight(hit);
+
+        float sky = lightLevelBrightness(levels.x);
+        float block = lightLevelBrightness(levels.y);
+
+        light = (ambientColor * sky + sunColor * diffuse * sky + blockLightColor * block) * ambientOcclusion(hit);
+
+    } else {
+
+        float shadow = 1.0;
+
+        if (diffuse > 0.0) {
+            shadow = sunShadow(hit.position, hit.normal);
+        }
+
+        light = (ambientColor + sunColor * diffuse * shadow + pointLighting(hit)) * ambientOcclusion(hit);
+
+    }
+
+    float emission = hit.material < materials.length() ? materials[hit.material].emission : 0.0;
+
+    return hit.color.rgb * (light + vec3(emission));
+}
+
+/* -- [[ Sky & Fog ]] -- */
+
+vec3 skyColor(vec3 rd) {
+
+    vec3 color;
+
+    if (rd.y >= 0.0) {
+        color = mix(skyHorizonColor, skyZenithColor, sqrt(rd.y));
+    } else {
+        color = mix(skyHorizonColor, skyGroundColor, sqrt(-rd.y));
+    }
+
+    // Sun disc with a soft glow, tied to the lighting direction
+
+    float sunAmount = max(dot(rd, sunDirection), 0.0);
+    float disc = smoothstep(sunDiscCos - 0.0002, sunDiscCos, sunAmount);
+
+    color += sunColor * pow(sunAmount, 64.0) * 0.25;
+    color += sunColor * disc * sunDiscIntensity;
+
+    return color;
+}
+
+vec3 applyFog(vec3 color, float t) {
+
+    float fogAmount = 1.0 - exp(-fogDensity * max(t - fogStart, 0.0));
+
+    return mix(color, fogColor, clamp(fogAmount, 0.0, 1.0));
+}
+
+/* -- [[ Transparency ]] -- */
+
+float fresnelSchlick(float cosTheta, float ior) {
+    float r0 = (1.0 - ior) / (1.0 + ior);
+    r0 *= r0;
+    return r0 + (1.0 - r0) * pow(1.0 - cosTheta, 5.0);
+}
+
+// Follows the ray through transparent voxels, compositing front to back
+const float SKY_DEPTH = 1e6;
+
+vec3 traceScene(vec3 ro, vec3 rd, out float primaryDistance) {
+
+    primaryDistance = SKY_DEPTH;
+
+    vec3 color = vec3(0.0);
+    vec3 transmittance = vec3(1.0);
+
+    vec3 origin = ro;
+    vec3 dir = rd;
+    float travelled = 0.0;
+    uint inside = MATERIAL_AIR;
+
+    for (int depth = 0; depth <= maxTransparencyDepth; depth++) {
+
+        RayHit hit;
+
+        if (!traverseChunks(origin, dir, false, hit)) {
+            return color + transmittance * skyColor(dir);
+        }
+
+        if (depth == 0) {
+            primaryDistance = hit.t;
+        }
+
+        float distance = travelled + hit.t;
+
+        if (!isTransparent(hit.material) || depth == maxTransparencyDepth) {
+            return color + transmittance * applyFog(shade(hit), distance);
+        }
+
+        Material material = materials[hit.material];
+
+        // Voxels directly behind one of the same material are inside the volume, only the surface refracts
+
+        bool entering = hit.material != inside || hit.t > chunkScale * 0.5;
+
+        if (entering && material.ior > 0.0) {
+
+            float fresnel = fresnelSchlick(clamp(dot(-dir, hit.normal), 0.0, 1.0), material.ior);
+
+            color += transmittance * fresnel * applyFog(skyColor(reflect(dir, hit.normal)), distance);
+            transmittance *= 1.0 - fresnel;
+
+            vec3 refracted = refract(dir, hit.normal, 1.0 / material.ior);
+
+            if (refracted != vec3(0.0)) {
+                dir = normalize(refracted);
+            }
+
+        }
+
+        float alpha = material.opacity;
+
+        color += transmittance * alpha * applyFog(shade(hit), distance);
+        transmittance *= (1.0 - alpha) * mix(vec3(1.0), hit.color.rgb, alpha);
+
+        if (max(transmittance.r, max(transmittance.g, transmittance.b)) < 0.01) {
+            return color;
+        }
+
+        // Continue from where the ray leaves the voxel
+
+        float tNear, tFar;
+        intersectAABB(hit.position, dir, hit.boxMin, hit.boxMax, tNear, tFar);
+
+        tFar = max(tFar, 0.0);
+
+        origin = hit.position + dir * (tFar + chunkScale * 0.001);
+        travelled = distance + tFar;
+        inside = hit.material;
+
+    }
+
+    return color;
+}
+
+/* -- [[ Debug Views ]] -- */
+
+// Must match world/debugview.go
+
+vec3 debugHeatmap(float value) {
+    value = clamp(value, 0.0, 1.0);
+    return clamp(vec3(1.5) - abs(vec3(4.0 * value) - vec3(3.0, 2.0, 1.0)), 0.0, 1.0);
+}
+
+vec3 debugChunkColor(ivec3 chunk) {
+    uint h = hash3D(chunk);
+    return 0.3 + 0.7 * vec3(h & 255u, (h >> 8u) & 255u, (h >> 16u) & 255u) / 255.0;
+}
+
+vec3 debugColor(vec3 ro, vec3 rd, out float primaryDistance) {
+
+    primaryDistance = SKY_DEPTH;
+
+    RayHit hit;
+    bool found = traverseChunks(ro, rd, false, hit);
+
+    if (debugView == DEBUG_VIEW_STEPS) {
+        return debugHeatmap(float(traversalSteps) / float(DEBUG_STEP_SCALE));
+    }
+
+    if (!found) {
+        return vec3(0.0);
+    }
+
+    primaryDistance = hit.t;
+
+    if (debugView == DEBUG_VIEW_DEPTH) {
+
+        float nodeSize = (hit.boxMax.x - hit.boxMin.x) / chunkScale;
+        float maxDepth = log2(chunkSize);
+
+        return debugHeatmap((maxDepth - log2(nodeSize)) / maxDepth);
+
+    }
+
+    if (debugView == DEBUG_VIEW_CHUNKS) {
+
+        // Sample just inside the hit voxel so faces on a chunk border get the chunk they belong to
+
+        vec3 inside = hit.position - hit.normal * (0.5 * chunkScale);
+        vec3 local = fract(hit.position / (chunkSize * chunkScale));
+        vec3 edges = min(local, 1.0 - local) * chunkSize;
+
+        float edge = 1e30;
+
+        for (int axis = 0; axis < 3; axis++) {
+            if (hit.normal[axis] == 0.0) {
+                edge = min(edge, edges[axis]);
+            }
+        }
+
+        vec3 color = debugChunkColor(getChunkPosition(inside));
+
+        return edge < 0.5 ? color * 0.25 : color;
+
+    }
+
+    if (debugView == DEBUG_VIEW_NORMALS) {
+        return hit.normal * 0.5 + 0.5;
+    }
+
+    if (debugView == DEBUG_VIEW_DISTANCE) {
+        return vec3(1.0 - clamp(hit.t / (float(MAX_STEPS) * chunkSize * chunkScale), 0.0, 1.0));
+    }
+
+    if (debugView == DEBUG_VIEW_LOD) {
+        return hit.lodCutoff ? vec3(1.0, 0.0, 1.0) : vec3(0.45 + 0.25 * hit.normal.y + 0.1 * hit.normal.x);
+    }
+
+    return vec3(0.0);
+}
+
 /* -- [[ Main function ]] -- */
 
 void main() {
     
-    vec2 uv = fragTexCoord * 2.0 - 1.0;
+    vec2 uv = (viewport.xy + fragTexCoord * viewport.zw + jitter / iResolution) * 2.0 - 1.0;
     uv.x *= iResolution.x / iResolution.y;
     
     vec3 ro = camPos;
 
     vec4 rayClip = vec4(uv, -1.0, 1.0);
 
-    vec3 rd_view = normalize(vec3(uv, -1.0));  // ray direction in view space
+    vec3 rd_view = normalize(vec3(uv * tan(radians(fov) * 0.5), -1.0));  // ray direction in view space, fov is vertical in degrees
     vec3 rd = normalize((invView * vec4(rd_view, 0.0)).xyz);
 
-    float closestT = 1e30;
-    vec4 finalColor = vec4(0.0);
+    float primaryDistance;
 
-    bool hit = traverseChunks(ro, rd, finalColor );
-
-    if (hit) {
-        FragColor = finalColor;
+    if (debugView != DEBUG_VIEW_OFF) {
+        FragColor = vec4(debugColor(ro, rd, primaryDistance), 1.0);
     } else {
-        FragColor = vec4( vec3(0.3), 1.0 );
+        FragColor = vec4(traceScene(ro, rd, primaryDistance), 1.0);
     }
 
+    FragDepth = primaryDistance;
+
 }
\ No newline at end of file
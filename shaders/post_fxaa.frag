@@ -0,0 +1,64 @@
+#version 330 core
+
+in vec2 TexCoords;
+out vec4 FragColor;
+
+uniform sampler2D tex;
+
+const float FXAA_SPAN_MAX = 8.0;
+const float FXAA_REDUCE_MUL = 1.0 / 8.0;
+const float FXAA_REDUCE_MIN = 1.0 / 128.0;
+
+float luma(vec3 color) {
+    return dot(color, vec3(0.299, 0.587, 0.114));
+}
+
+void main() {
+
+    vec2 texel = 1.0 / vec2(textureSize(tex, 0));
+
+    vec3 rgbNW = texture(tex, TexCoords + vec2(-1.0, -1.0) * texel).rgb;
+    vec3 rgbNE = texture(tex, TexCoords + vec2(1.0, -1.0) * texel).rgb;
+    vec3 rgbSW = texture(tex, TexCoords + vec2(-1.0, 1.0) * texel).rgb;
+    vec3 rgbSE = texture(tex, TexCoords + vec2(1.0, 1.0) * texel).rgb;
+    vec3 rgbM = texture(tex, TexCoords).rgb;
+
+    float lumaNW = luma(rgbNW);
+    float lumaNE = luma(rgbNE);
+    float lumaSW = luma(rgbSW);
+    float lumaSE = luma(rgbSE);
+    float lumaM = luma(rgbM);
+
+    float lumaMin = min(lumaM, min(min(lumaNW, lumaNE), min(lumaSW, lumaSE)));
+    float lumaMax = max(lumaM, max(max(lumaNW, lumaNE), max(lumaSW, lumaSE)));
+
+    // Blur along the edge, perpendicular to the luma gradient
+
+    vec2 dir = vec2(
+        -((lumaNW + lumaNE) - (lumaSW + lumaSE)),
+        (lumaNW + lumaSW) - (lumaNE + lumaSE)
+    );
+
+    float dirReduce = max((lumaNW + lumaNE + lumaSW + lumaSE) * 0.25 * FXAA_REDUCE_MUL, FXAA_REDUCE_MIN);
+    float rcpDirMin = 1.0 / (min(abs(dir.x), abs(dir.y)) + dirReduce);
+
+    dir = clamp(dir * rcpDirMin, vec2(-FXAA_SPAN_MAX), vec2(FXAA_SPAN_MAX)) * texel;
+
+    vec3 rgbA = 0.5 * (
+        texture(tex, TexCoords + dir * (1.0 / 3.0 - 0.5)).rgb +
+        texture(tex, TexCoords + dir * (2.0 / 3.0 - 0.5)).rgb
+    );
+
+    vec3 rgbB = rgbA * 0.5 + 0.25 * (
+        texture(tex, TexCoords + dir * -0.5).rgb +
+        texture(tex, TexCoords + dir * 0.5).rgb
+    );
+
+    float lumaB = luma(rgbB);
+
+    if (lumaB < lumaMin || lumaB > lumaMax) {
+        FragColor = vec4(rgbA, 1.0);
+    } else {
+        FragColor = vec4(rgbB, 1.0);
+    }
+}
@@ -0,0 +1,12 @@
+#version 330 core
+
+in vec2 TexCoords;
+out vec4 FragColor;
+
+uniform sampler2D tex;
+uniform float gamma;
+
+void main() {
+    vec3 color = texture(tex, TexCoords).rgb;
+    FragColor = vec4(pow(max(color, vec3(0.0)), vec3(1.0 / gamma)), 1.0);
+}
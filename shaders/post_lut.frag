@@ -0,0 +1,22 @@
+#version 330 core
+
+in vec2 TexCoords;
+out vec4 FragColor;
+
+uniform sampler2D tex;
+uniform sampler3D lut;
+uniform float lutStrength;
+
+void main() {
+
+    vec3 color = clamp(texture(tex, TexCoords).rgb, 0.0, 1.0);
+
+    // Sample texel centres so the ends of the LUT are not blended with the border
+
+    float size = float(textureSize(lut, 0).x);
+    vec3 coords = color * ((size - 1.0) / size) + 0.5 / size;
+
+    vec3 graded = texture(lut, coords).rgb;
+
+    FragColor = vec4(mix(color, graded, lutStrength), 1.0);
+}
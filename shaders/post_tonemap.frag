@@ -0,0 +1,32 @@
+#version 330 core
+
+in vec2 TexCoords;
+out vec4 FragColor;
+
+uniform sampler2D tex;
+uniform float exposure;
+uniform int tonemapper;
+
+vec3 reinhard(vec3 color) {
+    return color / (1.0 + color);
+}
+
+// Narkowicz's fit of the ACES filmic curve
+vec3 aces(vec3 color) {
+    return clamp((color * (2.51 * color + 0.03)) / (color * (2.43 * color + 0.59) + 0.14), 0.0, 1.0);
+}
+
+void main() {
+
+    vec3 color = texture(tex, TexCoords).rgb * exposure;
+
+    if (tonemapper == TONEMAP_REINHARD) {
+        color = reinhard(color);
+    } else if (tonemapper == TONEMAP_ACES) {
+        color = aces(color);
+    } else {
+        color = clamp(color, 0.0, 1.0);
+    }
+
+    FragColor = vec4(color, 1.0);
+}
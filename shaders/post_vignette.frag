@@ -0,0 +1,18 @@
+#version 330 core
+
+in vec2 TexCoords;
+out vec4 FragColor;
+
+uniform sampler2D tex;
+uniform float vignetteStrength;
+uniform float vignetteRadius; // Distance from the centre where darkening starts, 1 is the corner
+
+void main() {
+
+    vec3 color = texture(tex, TexCoords).rgb;
+
+    float distance = length(TexCoords - 0.5) / length(vec2(0.5));
+    float vignette = 1.0 - smoothstep(vignetteRadius, 1.0, distance) * vignetteStrength;
+
+    FragColor = vec4(color * vignette, 1.0);
+}
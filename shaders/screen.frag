@@ -4,7 +4,17 @@ in vec2 TexCoords;
 out vec4 FragColor;
 
 uniform sampler2D tex;
+uniform int upscaleFilter;
 
 void main() {
+
+    // Nearest keeps the hard voxel edges when the world renders below window resolution
+
+    if (upscaleFilter == UPSCALE_NEAREST) {
+        ivec2 size = textureSize(tex, 0);
+        FragColor = texelFetch(tex, min(ivec2(TexCoords * vec2(size)), size - 1), 0);
+        return;
+    }
+
     FragColor = texture(tex, TexCoords);
 }
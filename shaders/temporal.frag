@@ -0,0 +1,90 @@
+#version 450 core
+
+/* -- [[ Temporal Accumulation ]] -- */
+
+// Blends the raymarched frame with the reprojected history, writing the new history
+
+in vec2 TexCoords;
+
+layout(location = 0) out vec4 HistoryColor;
+layout(location = 1) out float HistoryDepth;
+
+uniform sampler2D currentColor;
+uniform sampler2D currentDepth;
+uniform sampler2D historyColor;
+uniform sampler2D historyDepth;
+
+uniform mat4 invView;
+uniform vec3 camPos;
+uniform vec2 iResolution;
+uniform vec2 jitter; // Sub-pixel offset the raymarcher used this frame, in pixels
+
+uniform mat4 prevViewProjection;
+uniform vec3 prevCamPos;
+
+uniform int historyValid;
+uniform float historyWeight;   // How much of the history is kept each frame
+uniform float depthRejection;  // Relative depth difference treated as a disocclusion
+
+void main() {
+
+    vec2 texel = 1.0 / iResolution;
+
+    vec3 current = texture(currentColor, TexCoords).rgb;
+    float depth = texture(currentDepth, TexCoords).r;
+
+    HistoryDepth = depth;
+    HistoryColor = vec4(current, 1.0);
+
+    if (historyValid == 0) {
+        return;
+    }
+
+    // Rebuild the ray the raymarcher traced for this pixel
+
+    vec2 uv = (TexCoords + jitter * texel) * 2.0 - 1.0;
+    uv.x *= iResolution.x / iResolution.y;
+
+    vec3 rd = normalize((invView * vec4(normalize(vec3(uv, -1.0)), 0.0)).xyz);
+    vec3 worldPos = camPos + rd * depth;
+
+    // Find where the same point was on screen last frame
+
+    vec4 prevClip = prevViewProjection * vec4(worldPos, 1.0);
+
+    if (prevClip.w <= 0.0) {
+        return;
+    }
+
+    vec2 prevUV = prevClip.xy / prevClip.w * 0.5 + 0.5;
+
+    if (any(lessThan(prevUV, vec2(0.0))) || any(greaterThan(prevUV, vec2(1.0)))) {
+        return;
+    }
+
+    // Something else was visible there last frame
+
+    float expected = distance(worldPos, prevCamPos);
+    float prevDepth = texture(historyDepth, prevUV).r;
+
+    if (abs(prevDepth - expected) > depthRejection * expected) {
+        return;
+    }
+
+    // Clamp the history to the colours around this pixel to limit ghosting
+
+    vec3 minColor = current;
+    vec3 maxColor = current;
+
+    for (int x = -1; x <= 1; x++) {
+        for (int y = -1; y <= 1; y++) {
+            vec3 neighbour = texture(currentColor, TexCoords + vec2(x, y) * texel).rgb;
+            minColor = min(minColor, neighbour);
+            maxColor = max(maxColor, neighbour);
+        }
+    }
+
+    vec3 history = clamp(texture(historyColor, prevUV).rgb, minColor, maxColor);
+
+    HistoryColor = vec4(mix(current, history, historyWeight), 1.0);
+}
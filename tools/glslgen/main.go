@@ -0,0 +1,54 @@
+package main
+
+// glslgen writes the GLSL mirror of the world package GPU structs.
+//
+//	go generate ./world                 regenerate shaders/include/gpu_types.glsl
+//	go run ./tools/glslgen -check       fail if a layout breaks std430 or the checked-in file is stale
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	World "VoxelRPG/world"
+)
+
+func main() {
+
+	out := flag.String("out", "shaders/include/gpu_types.glsl", "File to write the generated GLSL to")
+	check := flag.Bool("check", false, "Compare against the existing file instead of writing it")
+
+	flag.Parse()
+
+	generated, err := World.GenerateGLSL()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "glslgen: layout error:", err)
+		os.Exit(1)
+	}
+
+	if *check {
+
+		existing, err := os.ReadFile(*out)
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "glslgen:", err)
+			os.Exit(1)
+		}
+
+		if string(existing) != generated {
+			fmt.Fprintln(os.Stderr, "glslgen:", *out, "is out of date with the Go types, run go generate ./world")
+			os.Exit(1)
+		}
+
+		fmt.Println("glslgen:", *out, "is up to date")
+		return
+
+	}
+
+	if err := os.WriteFile(*out, []byte(generated), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "glslgen:", err)
+		os.Exit(1)
+	}
+
+}
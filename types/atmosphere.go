@@ -0,0 +1,97 @@
+package types
+
+import (
+	"math"
+
+	GPU "VoxelRPG/gpu"
+	World "VoxelRPG/world"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type Atmosphere struct {
+	SkyZenithColor  mgl32.Vec3
+	SkyHorizonColor mgl32.Vec3
+	GroundColor     mgl32.Vec3
+	NightBrightness float32 // Minimum sky brightness once the sun has set
+
+	SunDiscSize      float32 // Angular radius of the sun disc in degrees
+	SunDiscIntensity float32
+
+	FogColor   mgl32.Vec3
+	FogDensity float32
+	FogStart   float32 // Distance from the camera before fog starts to build up
+}
+
+var WorldAtmosphere = NewAtmosphere()
+
+func NewAtmosphere() *Atmosphere {
+
+	horizon := mgl32.Vec3{0.7, 0.8, 0.9}
+
+	atmosphere := &Atmosphere{
+		SkyZenithColor:  mgl32.Vec3{0.25, 0.45, 0.8},
+		SkyHorizonColor: horizon,
+		GroundColor:     mgl32.Vec3{0.35, 0.33, 0.3},
+		NightBrightness: 0.05,
+
+		SunDiscSize:      0.8,
+		SunDiscIntensity: 8.0,
+
+		FogColor: horizon,
+	}
+
+	atmosphere.FitFogToRenderDistance(*World.RENDER_DISTANCE_POINTER)
+
+	return atmosphere
+
+}
+
+/* -- [[ Fog ]] -- */
+
+// FogDensityForDistance returns the density which fully fogs a hit at the end distance
+func FogDensityForDistance(start, end float32) float32 {
+
+	if end <= start {
+		return 0
+	}
+
+	// exp(-density * distance) reaches 1% at the end distance
+
+	return float32(-math.Log(0.01)) / (end - start)
+
+}
+
+// FitFogToRenderDistance fades out hits before the edge of the loaded chunks
+func (a *Atmosphere) FitFogToRenderDistance(renderDistance int) {
+
+	chunkWorldSize := float32(World.CHUNK_SIZE) * World.CHUNK_SCALE
+	end := float32(renderDistance) * chunkWorldSize
+
+	a.FogStart = end * 0.25
+	a.FogDensity = FogDensityForDistance(a.FogStart, end)
+
+}
+
+func (a *Atmosphere) Upload(shaderProgram *GPU.Program, lighting *Lighting) {
+
+	brightness := float32(math.Max(float64(lighting.Daylight()), float64(a.NightBrightness)))
+
+	zenith := a.SkyZenithColor.Mul(brightness)
+	horizon := a.SkyHorizonColor.Mul(brightness)
+	ground := a.GroundColor.Mul(brightness)
+	fog := a.FogColor.Mul(brightness)
+
+	sunDiscCos := float32(math.Cos(float64(mgl32.DegToRad(a.SunDiscSize))))
+
+	shaderProgram.SetVec3("skyZenithColor", zenith)
+	shaderProgram.SetVec3("skyHorizonColor", horizon)
+	shaderProgram.SetVec3("skyGroundColor", ground)
+	shaderProgram.SetFloat("sunDiscCos", sunDiscCos)
+	shaderProgram.SetFloat("sunDiscIntensity", a.SunDiscIntensity)
+
+	shaderProgram.SetVec3("fogColor", fog)
+	shaderProgram.SetFloat("fogDensity", a.FogDensity)
+	shaderProgram.SetFloat("fogStart", a.FogStart)
+
+}
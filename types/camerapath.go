@@ -0,0 +1,168 @@
+package types
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/* -- [[ Camera Paths ]] -- */
+
+// A camera path is a text file of keyframes, one per line as "time x y z yaw pitch" with time in
+// seconds and angles in degrees. Lines starting with # are comments. The camera follows a
+// Catmull-Rom spline through the keyframes, so recordings along the path are reproducible.
+
+type CameraKey struct {
+	Time float64
+	Pos  mgl32.Vec3
+
+	Yaw   float64
+	Pitch float64
+}
+
+type CameraPath struct {
+	Keys []CameraKey
+}
+
+func LoadCameraPath(file string) (*CameraPath, error) {
+
+	input, err := os.Open(file)
+
+	if err != nil {
+		return nil, fmt.Errorf("camera path %q not found on disk: %v", file, err)
+	}
+
+	defer input.Close()
+
+	path := &CameraPath{}
+	scanner := bufio.NewScanner(input)
+
+	for line := 1; scanner.Scan(); line++ {
+
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("%v:%d: expected time x y z yaw pitch, got %d values", file, line, len(fields))
+		}
+
+		var values [6]float64
+
+		for i, field := range fields {
+
+			values[i], err = strconv.ParseFloat(field, 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("%v:%d: %v", file, line, err)
+			}
+
+		}
+
+		key := CameraKey{
+			Time: values[0],
+			Pos:  mgl32.Vec3{float32(values[1]), float32(values[2]), float32(values[3])},
+
+			Yaw:   values[4],
+			Pitch: values[5],
+		}
+
+		if len(path.Keys) > 0 && key.Time <= path.Keys[len(path.Keys)-1].Time {
+			return nil, fmt.Errorf("%v:%d: keyframe times must increase", file, line)
+		}
+
+		path.Keys = append(path.Keys, key)
+
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(path.Keys) == 0 {
+		return nil, fmt.Errorf("camera path %q has no keyframes", file)
+	}
+
+	return path, nil
+
+}
+
+// Duration is the time of the last keyframe
+func (p *CameraPath) Duration() float64 {
+	return p.Keys[len(p.Keys)-1].Time
+}
+
+func catmullRom(p0, p1, p2, p3, t float64) float64 {
+
+	t2 := t * t
+	t3 := t2 * t
+
+	return 0.5 * (2*p1 + (p2-p0)*t + (2*p0-5*p1+4*p2-p3)*t2 + (3*p1-p0-3*p2+p3)*t3)
+
+}
+
+// Sample returns the camera position and angles at a time, holding the ends outside the path
+func (p *CameraPath) Sample(time float64) (mgl32.Vec3, float64, float64) {
+
+	last := len(p.Keys) - 1
+
+	if time <= p.Keys[0].Time || last == 0 {
+		return p.Keys[0].Pos, p.Keys[0].Yaw, p.Keys[0].Pitch
+	}
+
+	if time >= p.Keys[last].Time {
+		return p.Keys[last].Pos, p.Keys[last].Yaw, p.Keys[last].Pitch
+	}
+
+	segment := 0
+
+	for p.Keys[segment+1].Time < time {
+		segment++
+	}
+
+	k0 := p.Keys[max(segment-1, 0)]
+	k1 := p.Keys[segment]
+	k2 := p.Keys[segment+1]
+	k3 := p.Keys[min(segment+2, last)]
+
+	t := (time - k1.Time) / (k2.Time - k1.Time)
+
+	spline := func(value func(key CameraKey) float64) float64 {
+		return catmullRom(value(k0), value(k1), value(k2), value(k3), t)
+	}
+
+	pos := mgl32.Vec3{
+		float32(spline(func(key CameraKey) float64 { return float64(key.Pos.X()) })),
+		float32(spline(func(key CameraKey) float64 { return float64(key.Pos.Y()) })),
+		float32(spline(func(key CameraKey) float64 { return float64(key.Pos.Z()) })),
+	}
+
+	yaw := spline(func(key CameraKey) float64 { return key.Yaw })
+	pitch := ClampF64(spline(func(key CameraKey) float64 { return key.Pitch }), -89.99, 89.99)
+
+	return pos, yaw, pitch
+
+}
+
+// cameraFront matches the direction WindowMouseCB builds from the mouse angles
+func cameraFront(yaw, pitch float64) mgl32.Vec3 {
+
+	radYaw := yaw * math.Pi / 180
+	radPitch := pitch * math.Pi / 180
+
+	return mgl32.Vec3{
+		float32(math.Cos(radYaw) * math.Cos(radPitch)),
+		float32(math.Sin(radPitch)),
+		float32(math.Sin(radYaw) * math.Cos(radPitch)),
+	}.Normalize()
+
+}
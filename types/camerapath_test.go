@@ -0,0 +1,105 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestLoadCameraPath(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		text  string
+		keys  int
+		error string
+	}{
+		{"keys", "0 0 0 0 0 0\n1 10 0 0 90 0\n", 2, ""},
+		{"comments and blank lines", "# time x y z yaw pitch\n\n0 0 0 0 0 0\n  \n2.5 1 2 3 45 -10\n", 2, ""},
+		{"missing values", "0 0 0 0 0\n", 0, "expected time x y z yaw pitch, got 5 values"},
+		{"not a number", "0 0 0 zero 0 0\n", 0, "invalid syntax"},
+		{"times out of order", "1 0 0 0 0 0\n1 0 0 0 0 0\n", 0, ":2: keyframe times must increase"},
+		{"empty", "# nothing yet\n", 0, "has no keyframes"},
+	}
+
+	for _, test := range tests {
+
+		file := filepath.Join(t.TempDir(), "path.txt")
+
+		if err := os.WriteFile(file, []byte(test.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		path, err := LoadCameraPath(file)
+
+		if test.error != "" {
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("%v: error %v, want one containing %q", test.name, err, test.error)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		if len(path.Keys) != test.keys {
+			t.Errorf("%v: %d keys, want %d", test.name, len(path.Keys), test.keys)
+		}
+
+	}
+
+	if _, err := LoadCameraPath(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("missing file loaded without an error")
+	}
+
+}
+
+func TestCameraPathSample(t *testing.T) {
+
+	// Evenly spaced keys on a line, so the spline between the inner keys is the line itself
+
+	path := &CameraPath{Keys: []CameraKey{
+		{Time: 0, Pos: mgl32.Vec3{0, 0, 0}, Yaw: 0, Pitch: 0},
+		{Time: 1, Pos: mgl32.Vec3{10, 0, 0}, Yaw: 90, Pitch: 30},
+		{Time: 2, Pos: mgl32.Vec3{20, 0, 0}, Yaw: 180, Pitch: 60},
+		{Time: 3, Pos: mgl32.Vec3{30, 0, 0}, Yaw: 270, Pitch: 120},
+	}}
+
+	tests := []struct {
+		name  string
+		time  float64
+		pos   mgl32.Vec3
+		yaw   float64
+		pitch float64
+	}{
+		{"before the start", -1, mgl32.Vec3{0, 0, 0}, 0, 0},
+		{"first key", 0, mgl32.Vec3{0, 0, 0}, 0, 0},
+		{"inner key", 1, mgl32.Vec3{10, 0, 0}, 90, 30},
+		{"between inner keys", 1.5, mgl32.Vec3{15, 0, 0}, 135, 43.125},
+		{"first segment repeats the first key", 0.5, mgl32.Vec3{4.375, 0, 0}, 39.375, 13.125},
+		{"pitch is clamped", 2.9, mgl32.Vec3{29.405, 0, 0}, 264.645, 89.99},
+		{"after the end", 4, mgl32.Vec3{30, 0, 0}, 270, 120},
+	}
+
+	for _, test := range tests {
+
+		pos, yaw, pitch := path.Sample(test.time)
+
+		if !pos.ApproxEqualThreshold(test.pos, 1e-3) || !mgl32.FloatEqualThreshold(float32(yaw), float32(test.yaw), 1e-3) || !mgl32.FloatEqualThreshold(float32(pitch), float32(test.pitch), 1e-3) {
+			t.Errorf("%v: Sample(%v) = %v, %v, %v, want %v, %v, %v", test.name, test.time, pos, yaw, pitch, test.pos, test.yaw, test.pitch)
+		}
+
+	}
+
+	single := &CameraPath{Keys: []CameraKey{{Time: 5, Pos: mgl32.Vec3{1, 2, 3}, Yaw: 10, Pitch: 20}}}
+
+	if pos, yaw, pitch := single.Sample(7); pos != (mgl32.Vec3{1, 2, 3}) || yaw != 10 || pitch != 20 {
+		t.Errorf("single key path sampled %v, %v, %v", pos, yaw, pitch)
+	}
+
+}
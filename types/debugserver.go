@@ -0,0 +1,470 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"sync"
+	"time"
+
+	Client "VoxelRPG/client"
+	Log "VoxelRPG/logging"
+	Profiling "VoxelRPG/profiling"
+	World "VoxelRPG/world"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/* -- [[ Debug HTTP Server ]] -- */
+
+// An opt-in HTTP server on a loopback address for scripts and tests. It serves pprof under
+// /debug/pprof/ and engine state as JSON:
+//
+//	GET  /debug/state            Everything below in one object
+//	GET  /debug/world            Chunks, octree nodes, SSBO usage and hash table stats
+//	GET  /debug/camera           Camera position and angles
+//	GET  /debug/timings          Frame, GPU pass and CPU scope timings
+//	POST /debug/camera           {"position": [x, y, z], "yaw": 90, "pitch": 0}, all optional
+//	POST /debug/render-distance  {"distance": 5}
+//
+// Engine state belongs to the render loop, so handlers queue their work and Poll runs it there
+// at the start of the next frame.
+
+const DebugRequestTimeout = 30 * time.Second // Changing the render distance regenerates the world
+
+type debugCommand struct {
+	run    func() (any, error)
+	result chan debugResult
+}
+
+type debugResult struct {
+	value any
+	err   error
+}
+
+type DebugHTTPServer struct {
+	Addr string // Loopback host:port to listen on, empty to leave the server off
+
+	client   *Client.ClientContext
+	listener net.Listener
+	server   *http.Server
+
+	commands chan debugCommand
+	closing  chan struct{}
+	once     sync.Once
+}
+
+var DebugServer = &DebugHTTPServer{
+	Addr: os.Getenv("VOXELRPG_DEBUG_ADDR"),
+}
+
+// Start listens on Addr, port 0 picks a free port which ListenAddr then reports
+func (d *DebugHTTPServer) Start(client *Client.ClientContext) error {
+
+	if d.Addr == "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(d.Addr)
+
+	if err != nil {
+		return fmt.Errorf("debug server address %q: %v", d.Addr, err)
+	}
+
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("debug server address %q is not a loopback address", d.Addr)
+	}
+
+	listener, err := net.Listen("tcp", d.Addr)
+
+	if err != nil {
+		return fmt.Errorf("debug server: %v", err)
+	}
+
+	d.client = client
+	d.listener = listener
+	d.commands = make(chan debugCommand)
+	d.closing = make(chan struct{})
+
+	d.server = &http.Server{
+		Handler:           d.routes(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+
+		if err := d.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			Log.Main.Error("Debug server stopped", "error", err)
+		}
+
+	}()
+
+	Log.Main.Info("Debug server listening", "url", "http://"+listener.Addr().String()+"/debug/state")
+
+	return nil
+
+}
+
+// ListenAddr is the address the server is listening on, empty when it is not running
+func (d *DebugHTTPServer) ListenAddr() string {
+
+	if d.listener == nil {
+		return ""
+	}
+
+	return d.listener.Addr().String()
+
+}
+
+func (d *DebugHTTPServer) Close() {
+
+	if d.server == nil {
+		return
+	}
+
+	d.once.Do(func() {
+		close(d.closing)
+		d.server.Close()
+	})
+
+}
+
+// Poll runs the commands waiting on the render loop, called once per frame
+func (d *DebugHTTPServer) Poll() {
+
+	if d.commands == nil {
+		return
+	}
+
+	for {
+
+		select {
+
+		case command := <-d.commands:
+
+			value, err := command.run()
+			command.result <- debugResult{value, err}
+
+		default:
+			return
+
+		}
+
+	}
+
+}
+
+// onRenderLoop hands work to Poll and waits for it, giving up with the request or the timeout
+func (d *DebugHTTPServer) onRenderLoop(request *http.Request, run func() (any, error)) (any, error) {
+
+	command := debugCommand{run: run, result: make(chan debugResult, 1)}
+	timeout := time.After(DebugRequestTimeout)
+
+	select {
+	case d.commands <- command:
+	case <-request.Context().Done():
+		return nil, request.Context().Err()
+	case <-d.closing:
+		return nil, errors.New("debug server is closing")
+	case <-timeout:
+		return nil, errors.New("render loop did not pick up the request")
+	}
+
+	// Once Poll has the command it always finishes it
+
+	result := <-command.result
+
+	return result.value, result.err
+
+}
+
+/* -- [[ Routes ]] -- */
+
+func (d *DebugHTTPServer) routes() *http.ServeMux {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("GET /debug/state", d.handle(func(*http.Request) (any, error) {
+		return DebugState{World: d.worldState(), Camera: d.cameraState(), Timings: timingState()}, nil
+	}))
+
+	mux.HandleFunc("GET /debug/world", d.handle(func(*http.Request) (any, error) {
+		return d.worldState(), nil
+	}))
+
+	mux.HandleFunc("GET /debug/camera", d.handle(func(*http.Request) (any, error) {
+		return d.cameraState(), nil
+	}))
+
+	mux.HandleFunc("GET /debug/timings", d.handle(func(*http.Request) (any, error) {
+		return timingState(), nil
+	}))
+
+	mux.HandleFunc("POST /debug/camera", handleWithBody(d, func(body *DebugCameraRequest) (any, error) {
+		return d.moveCamera(body)
+	}))
+
+	mux.HandleFunc("POST /debug/render-distance", handleWithBody(d, func(body *DebugRenderDistanceRequest) (any, error) {
+
+		err := World.MainWorld.SetRenderDistance(body.Distance)
+
+		if errors.Is(err, World.ErrInvalidRenderDistance) {
+			return nil, debugBadRequest{err}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		WorldAtmosphere.FitFogToRenderDistance(body.Distance)
+
+		return d.worldState(), nil
+
+	}))
+
+	return mux
+
+}
+
+// handle runs a handler on the render loop and writes its result as JSON
+func (d *DebugHTTPServer) handle(handler func(*http.Request) (any, error)) http.HandlerFunc {
+
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		value, err := d.onRenderLoop(request, func() (any, error) {
+			return handler(request)
+		})
+
+		writeJSON(writer, value, err)
+
+	}
+
+}
+
+// handleWithBody decodes the JSON body before handing it to the render loop
+func handleWithBody[T any](d *DebugHTTPServer, handler func(body *T) (any, error)) http.HandlerFunc {
+
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		body := new(T)
+		decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, 1<<16))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(body); err != nil {
+			writeJSON(writer, nil, debugBadRequest{err})
+			return
+		}
+
+		value, err := d.onRenderLoop(request, func() (any, error) {
+			return handler(body)
+		})
+
+		writeJSON(writer, value, err)
+
+	}
+
+}
+
+// debugBadRequest marks errors caused by the request rather than the engine
+type debugBadRequest struct {
+	err error
+}
+
+func (e debugBadRequest) Error() string {
+	return e.err.Error()
+}
+
+func writeJSON(writer http.ResponseWriter, value any, err error) {
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	if err != nil {
+
+		status := http.StatusInternalServerError
+
+		var badRequest debugBadRequest
+
+		if errors.As(err, &badRequest) {
+			status = http.StatusBadRequest
+		}
+
+		writer.WriteHeader(status)
+		json.NewEncoder(writer).Encode(map[string]string{"error": err.Error()})
+
+		return
+
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+
+}
+
+/* -- [[ State ]] -- */
+
+type DebugState struct {
+	World   DebugWorldState   `json:"world"`
+	Camera  DebugCameraState  `json:"camera"`
+	Timings DebugTimingsState `json:"timings"`
+}
+
+type DebugWorldState struct {
+	RenderDistance    int `json:"renderDistance"`
+	MaxRenderDistance int `json:"maxRenderDistance"`
+
+	Chunks      int `json:"chunks"`
+	DirtyChunks int `json:"dirtyChunks"`
+	Lights      int `json:"lights"`
+
+	OctreeNodes   int `json:"octreeNodes"`
+	NodesPerChunk int `json:"nodesPerChunk"`
+
+	SSBOBytesUsed      int     `json:"ssboBytesUsed"`
+	SSBOBytesAllocated int     `json:"ssboBytesAllocated"`
+	SSBOUsage          float64 `json:"ssboUsage"`
+
+	HashTable      *World.HashTableStats `json:"hashTable,omitempty"`
+	HashTableError string                `json:"hashTableError,omitempty"`
+}
+
+type DebugCameraState struct {
+	Position mgl32.Vec3 `json:"position"`
+	Front    mgl32.Vec3 `json:"front"`
+	Yaw      float64    `json:"yaw"`
+	Pitch    float64    `json:"pitch"`
+	Chunk    World.Vec3 `json:"chunk"`
+}
+
+type DebugScopeTiming struct {
+	Count int     `json:"count"`
+	Total float64 `json:"totalMs"`
+	Avg   float64 `json:"avgMs"`
+	Max   float64 `json:"maxMs"`
+}
+
+type DebugTimingsState struct {
+	Frame TimingSummary               `json:"frame"`
+	GPU   map[string]TimingSummary    `json:"gpu"`
+	CPU   map[string]DebugScopeTiming `json:"cpu"` // Keyed by scope path
+}
+
+func (d *DebugHTTPServer) worldState() DebugWorldState {
+
+	w := World.MainWorld
+
+	state := DebugWorldState{
+		RenderDistance:    *w.RenderDistance,
+		MaxRenderDistance: World.MaxRenderDistance(),
+
+		Chunks:      len(w.Chunks),
+		DirtyChunks: len(w.DirtyChunks),
+		Lights:      len(w.Lights),
+
+		OctreeNodes:   w.OctreeNodes(),
+		NodesPerChunk: World.NodesRequired,
+
+		SSBOBytesUsed:      w.OctreeNodes() * World.OctreeNodeByteSize,
+		SSBOBytesAllocated: w.NodeCapacity * World.OctreeNodeByteSize,
+	}
+
+	if state.SSBOBytesAllocated > 0 {
+		state.SSBOUsage = float64(state.SSBOBytesUsed) / float64(state.SSBOBytesAllocated)
+	}
+
+	stats, err := w.HashTableStats()
+
+	if err != nil {
+		state.HashTableError = err.Error()
+	} else {
+		state.HashTable = &stats
+	}
+
+	return state
+
+}
+
+func (d *DebugHTTPServer) cameraState() DebugCameraState {
+
+	cam := d.client.Camera
+
+	return DebugCameraState{
+		Position: cam.Pos,
+		Front:    cam.Front,
+		Yaw:      cam.Yaw,
+		Pitch:    cam.Pitch,
+		Chunk:    World.GetCameraChunk(cam.Pos),
+	}
+
+}
+
+func timingState() DebugTimingsState {
+
+	milliseconds := func(duration time.Duration) float64 {
+		return float64(duration.Nanoseconds()) / 1e6
+	}
+
+	state := DebugTimingsState{
+		Frame: FrameTimes.Summary(),
+		GPU:   GPUProfiler.Summaries(),
+		CPU:   map[string]DebugScopeTiming{},
+	}
+
+	for _, total := range Profiling.Totals() {
+		state.CPU[total.Path] = DebugScopeTiming{
+			Count: total.Count,
+			Total: milliseconds(total.Total),
+			Avg:   milliseconds(total.Avg()),
+			Max:   milliseconds(total.Max),
+		}
+	}
+
+	return state
+
+}
+
+/* -- [[ Commands ]] -- */
+
+type DebugCameraRequest struct {
+	Position *mgl32.Vec3 `json:"position"`
+	Yaw      *float64    `json:"yaw"`
+	Pitch    *float64    `json:"pitch"`
+}
+
+type DebugRenderDistanceRequest struct {
+	Distance int `json:"distance"`
+}
+
+func (d *DebugHTTPServer) moveCamera(body *DebugCameraRequest) (any, error) {
+
+	cam := d.client.Camera
+
+	if body.Position != nil {
+		cam.Pos = *body.Position
+	}
+
+	if body.Yaw != nil {
+		cam.Yaw = *body.Yaw
+	}
+
+	if body.Pitch != nil {
+		cam.Pitch = ClampF64(*body.Pitch, -89.99, 89.99)
+	}
+
+	cam.Front = cameraFront(cam.Yaw, cam.Pitch)
+
+	Log.Client.Info("Camera moved by the debug server", "position", cam.Pos, "yaw", cam.Yaw, "pitch", cam.Pitch)
+
+	return d.cameraState(), nil
+
+}
@@ -0,0 +1,164 @@
+package types
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	Client "VoxelRPG/client"
+	World "VoxelRPG/world"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// startDebugServer listens on a free loopback port with a goroutine standing in for the render
+// loop's Poll, over an empty world that uploads into a RecordingBackend
+func startDebugServer(t *testing.T) string {
+
+	client, err := Client.NewClient()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mainWorld := World.MainWorld
+	distance := 2
+
+	World.MainWorld = &World.World{RenderDistance: &distance, Backend: World.NewRecordingBackend()}
+
+	server := &DebugHTTPServer{Addr: "127.0.0.1:0"}
+
+	if err := server.Start(client); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+
+		defer close(stopped)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				server.Poll()
+			}
+		}
+
+	}()
+
+	t.Cleanup(func() {
+		server.Close()
+		close(stop)
+		<-stopped
+		World.MainWorld = mainWorld
+	})
+
+	return "http://" + server.ListenAddr()
+
+}
+
+// debugRequest sends a request and decodes the JSON reply into value, returning the status code
+func debugRequest(t *testing.T, method string, url string, body string, value any) int {
+
+	t.Helper()
+
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+		t.Fatalf("%v %v: invalid JSON: %v", method, url, err)
+	}
+
+	return response.StatusCode
+
+}
+
+func TestDebugServerState(t *testing.T) {
+
+	url := startDebugServer(t)
+
+	var state DebugState
+
+	if status := debugRequest(t, "GET", url+"/debug/state", "", &state); status != http.StatusOK {
+		t.Fatalf("GET /debug/state returned %d", status)
+	}
+
+	if state.World.RenderDistance != 2 || state.World.Chunks != 0 {
+		t.Errorf("world state %+v, want render distance 2 and no chunks", state.World)
+	}
+
+	if state.Camera.Yaw != 90 {
+		t.Errorf("camera state %+v, want the new client's yaw of 90", state.Camera)
+	}
+
+}
+
+func TestDebugServerMovesCamera(t *testing.T) {
+
+	url := startDebugServer(t)
+
+	var moved DebugCameraState
+
+	if status := debugRequest(t, "POST", url+"/debug/camera", `{"position": [1, 2, 3], "yaw": 0, "pitch": 100}`, &moved); status != http.StatusOK {
+		t.Fatalf("POST /debug/camera returned %d", status)
+	}
+
+	var camera DebugCameraState
+
+	debugRequest(t, "GET", url+"/debug/camera", "", &camera)
+
+	if camera.Position != (mgl32.Vec3{1, 2, 3}) || camera.Yaw != 0 || camera.Pitch != 89.99 {
+		t.Errorf("camera after the move %+v, want position [1 2 3], yaw 0 and the pitch clamped to 89.99", camera)
+	}
+
+	if camera != moved {
+		t.Errorf("POST replied %+v, GET reads %+v", moved, camera)
+	}
+
+}
+
+func TestDebugServerRejectsBadRenderDistance(t *testing.T) {
+
+	url := startDebugServer(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"out of range", `{"distance": 0}`},
+		{"unknown field", `{"distance": 1, "radius": 4}`},
+		{"not JSON", `distance=1`},
+	}
+
+	for _, test := range tests {
+
+		var reply map[string]string
+
+		if status := debugRequest(t, "POST", url+"/debug/render-distance", test.body, &reply); status != http.StatusBadRequest {
+			t.Errorf("%v: status %d, want %d", test.name, status, http.StatusBadRequest)
+		}
+
+		if reply["error"] == "" {
+			t.Errorf("%v: reply %v has no error", test.name, reply)
+		}
+
+	}
+
+}
@@ -0,0 +1,35 @@
+package types
+
+import (
+	Client "VoxelRPG/client"
+	Log "VoxelRPG/logging"
+	World "VoxelRPG/world"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+/* -- [[ Debug Views ]] -- */
+
+var (
+	DebugView World.DebugView
+
+	DebugViewKey = glfw.KeyF6
+)
+
+func SetDebugView(view World.DebugView) {
+
+	DebugView = view
+
+	Log.GL.Info("Debug view changed", "view", view)
+
+}
+
+func SetupDebugKeybinds(client *Client.ClientContext) {
+
+	Client.CBOnKeyChange(client, DebugViewKey, func(action glfw.Action) {
+		if action == glfw.Press {
+			SetDebugView(DebugView.Next())
+		}
+	})
+
+}
@@ -2,15 +2,99 @@ package types
 
 import (
 	"fmt"
-	"log"
-	"runtime/debug"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
 )
 
-func CheckError(err error) {
+/* -- [[ Errors ]] -- */
+
+// SetupError is a failure while starting the engine, Stage names the step that failed
+type SetupError struct {
+	Stage string
+	Err   error
+}
+
+func (e *SetupError) Error() string {
+	return e.Stage + ": " + e.Err.Error()
+}
+
+func (e *SetupError) Unwrap() error {
+	return e.Err
+}
+
+// ShaderError is a shader which failed to load, compile or link. Shader failures are fatal at
+// startup, a failed hot reload keeps the previous programs running.
+type ShaderError struct {
+	Program string // Empty until the error reaches the program being built
+	File    string // Empty for link errors
+	Log     string // Driver log, annotated with the original file and line where possible
+
+	Err error // Set instead of Log when the source could not be loaded
+}
 
-	if err != nil {
-		fmt.Println(string(debug.Stack()))
-		log.Fatalln("Error has occured:", err.Error())
+func (e *ShaderError) Error() string {
+
+	switch {
+	case e.Err != nil:
+		return e.Err.Error()
+	case e.File != "":
+		return fmt.Sprintf("failed to compile %v\n%v", e.File, e.Log)
+	default:
+		return fmt.Sprintf("failed to link program %v: %v", e.Program, e.Log)
 	}
 
 }
+
+func (e *ShaderError) Unwrap() error {
+	return e.Err
+}
+
+// ContextError is an OpenGL version the driver can not create a context for
+type ContextError struct {
+	Major, Minor int
+	Version      string // What the driver gave instead, empty when it refused to create a context
+	Err          error
+}
+
+func (e *ContextError) Error() string {
+
+	message := fmt.Sprintf("OpenGL %d.%d core is required, update the graphics driver or use a GPU that supports it", e.Major, e.Minor)
+
+	if e.Version != "" {
+		message += " (got " + e.Version + ")"
+	}
+
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+
+	return message
+
+}
+
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
+
+// FramebufferError is a framebuffer that is not complete after creating or resizing it
+type FramebufferError struct {
+	Name   string
+	Status uint32
+}
+
+func (e *FramebufferError) Error() string {
+	return fmt.Sprintf("%v framebuffer is not complete (status 0x%x)", e.Name, e.Status)
+}
+
+// checkFramebuffer checks the bound framebuffer
+func checkFramebuffer(name string) error {
+
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		return &FramebufferError{Name: name, Status: status}
+	}
+
+	return nil
+
+}
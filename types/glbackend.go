@@ -0,0 +1,182 @@
+package types
+
+import (
+	"errors"
+	"unsafe"
+
+	GPU "VoxelRPG/gpu"
+	World "VoxelRPG/world"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+/* -- [[ OpenGL World Backend ]] -- */
+
+// GLBackend keeps the world in shader storage buffers and raymarches it into the scene FBO,
+// every method must run on the GL thread
+type GLBackend struct {
+	program    **GPU.Program // The raymarcher, read through the pointer so shader reloads are picked up
+	target     uint32        // Scene FBO
+	quad       uint32        // Fullscreen quad VAO
+	lighting   *Lighting
+	atmosphere *Atmosphere
+
+	nodes         uint32
+	chunkInfo     uint32
+	displacements uint32
+	materials     uint32
+	lights        uint32
+}
+
+func NewGLBackend(program **GPU.Program, target uint32, quad uint32, lighting *Lighting, atmosphere *Atmosphere) *GLBackend {
+	return &GLBackend{
+		program:    program,
+		target:     target,
+		quad:       quad,
+		lighting:   lighting,
+		atmosphere: atmosphere,
+	}
+}
+
+// storageBuffer generates the buffer on first use and binds it
+func storageBuffer(buffer *uint32, label string) {
+
+	if *buffer == 0 {
+		gl.GenBuffers(1, buffer)
+		GPU.LabelBuffer(gl.SHADER_STORAGE_BUFFER, *buffer, label)
+	}
+
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, *buffer)
+
+}
+
+// uploadStorage replaces the contents of a buffer and binds it to the shaders
+func uploadStorage[T any](buffer *uint32, label string, binding uint32, data []T, usage uint32) {
+
+	storageBuffer(buffer, label)
+
+	var pointer unsafe.Pointer
+
+	if len(data) > 0 {
+		pointer = gl.Ptr(data)
+	}
+
+	gl.BufferData(gl.SHADER_STORAGE_BUFFER, len(data)*int(unsafe.Sizeof(data[0])), pointer, usage)
+
+	GPU.BindStorageBuffer(binding, *buffer)
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+
+}
+
+func (b *GLBackend) AllocateNodes(nodes int) error {
+
+	storageBuffer(&b.nodes, "Octree Nodes SSBO")
+
+	if b.nodes == 0 {
+		return errors.New("failed to generate the octree node SSBO")
+	}
+
+	gl.BufferData(gl.SHADER_STORAGE_BUFFER, nodes*World.OctreeNodeByteSize, nil, gl.DYNAMIC_DRAW)
+
+	GPU.BindStorageBuffer(GPU.BindingNodes, b.nodes)
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+
+	return nil
+
+}
+
+func (b *GLBackend) UploadNodes(offset uint32, nodes []World.GridNodeFlatGPU) {
+
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, b.nodes)
+	gl.BufferSubData(gl.SHADER_STORAGE_BUFFER, int(offset)*World.OctreeNodeByteSize, len(nodes)*World.OctreeNodeByteSize, unsafe.Pointer(&nodes[0]))
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+
+}
+
+func (b *GLBackend) ClearNodes(offset uint32, count int) {
+
+	zeroBytes := make([]byte, count*World.OctreeNodeByteSize)
+
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, b.nodes)
+	gl.BufferSubData(gl.SHADER_STORAGE_BUFFER, int(offset)*World.OctreeNodeByteSize, len(zeroBytes), unsafe.Pointer(&zeroBytes[0]))
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+
+}
+
+func (b *GLBackend) UploadChunkIndex(displacements []uint32, entries []World.MapEntry) {
+
+	uploadStorage(&b.chunkInfo, "Chunk Info SSBO", GPU.BindingChunkInfo, entries, gl.STATIC_DRAW)
+	uploadStorage(&b.displacements, "Chunk Displacements SSBO", GPU.BindingDisplacements, displacements, gl.STATIC_DRAW)
+
+}
+
+func (b *GLBackend) UploadMaterials(materials []World.MaterialGPU) {
+	uploadStorage(&b.materials, "Material SSBO", GPU.BindingMaterials, materials, gl.STATIC_DRAW)
+}
+
+func (b *GLBackend) UploadLights(lights []World.LightGPU) {
+	uploadStorage(&b.lights, "Light SSBO", GPU.BindingLights, lights, gl.DYNAMIC_DRAW)
+}
+
+func (b *GLBackend) SetWorldParams(params World.WorldParams) {
+
+	program := *b.program
+
+	program.SetFloat("chunkSize", params.ChunkSize)
+	program.SetFloat("chunkScale", params.ChunkScale)
+	program.SetUint("numLights", params.Lights)
+
+}
+
+// DrawFrame raymarches into the scene FBO with the lighting and debug settings of this frame
+func (b *GLBackend) DrawFrame(frame World.FrameParams) {
+
+	program := *b.program
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, b.target)
+	gl.Viewport(0, 0, frame.Width, frame.Height)
+	program.Use()
+	gl.BindVertexArray(b.quad)
+
+	// === Uniform Uploads ===
+
+	program.SetMat4("invView", frame.InvView)
+	program.SetVec2("jitter", frame.Jitter)
+	program.SetVec4("viewport", frame.Viewport)
+	program.SetInt("debugView", int32(frame.DebugView))
+	program.SetVec3("camPos", frame.CameraPos)
+	program.SetFloat("iTime", frame.Time)
+	program.SetVec2("iResolution", frame.Resolution)
+	program.SetFloat("fov", frame.FOV)
+	program.SetInt("maxTransparencyDepth", frame.MaxTransparencyDepth)
+	program.SetInt("maxLightsPerHit", frame.MaxLightsPerHit)
+
+	// === Lighting ===
+
+	b.lighting.Upload(program)
+	b.atmosphere.Upload(program, b.lighting)
+
+	// === Bind SSBO ===
+
+	GPU.BindStorageBuffer(GPU.BindingNodes, b.nodes)
+
+	// === Draw Fullscreen Quad ===
+
+	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+
+}
+
+func (b *GLBackend) Close() {
+
+	buffers := []*uint32{&b.nodes, &b.chunkInfo, &b.displacements, &b.materials, &b.lights}
+
+	for _, buffer := range buffers {
+
+		if *buffer != 0 {
+			gl.DeleteBuffers(1, buffer)
+			*buffer = 0
+		}
+
+	}
+
+}
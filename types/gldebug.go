@@ -0,0 +1,223 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unsafe"
+
+	GPU "VoxelRPG/gpu"
+	Log "VoxelRPG/logging"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+/* -- [[ GL Debug Output ]] -- */
+
+type GLDebugSeverity int
+
+const (
+	GLDebugNotification GLDebugSeverity = iota
+	GLDebugLow
+	GLDebugMedium
+	GLDebugHigh
+)
+
+var glDebugSeverityNames = [...]string{"notification", "low", "medium", "high"}
+
+func (s GLDebugSeverity) String() string {
+	return glDebugSeverityNames[s]
+}
+
+// GLDebugSettings asks for a debug context and routes the driver's debug messages into Log.GL.
+// VOXELRPG_GL_DEBUG turns it on, either as 1 or as the lowest severity to log, and
+// VOXELRPG_GL_DEBUG_BREAK=1 panics on high severity messages so the stack shows the call.
+type GLDebugSettings struct {
+	Enabled     bool
+	MinSeverity GLDebugSeverity
+	PanicOnHigh bool
+}
+
+var GLDebug = newGLDebugSettings(os.Getenv("VOXELRPG_GL_DEBUG"), os.Getenv("VOXELRPG_GL_DEBUG_BREAK"))
+
+// glDebugLog keeps a message repeated every draw from flooding the log
+var glDebugLog = Log.GL.Every(time.Second)
+
+func newGLDebugSettings(value string, breakValue string) *GLDebugSettings {
+
+	settings := &GLDebugSettings{MinSeverity: GLDebugLow}
+
+	switch strings.ToLower(value) {
+	case "", "0", "off":
+		return settings
+	case "1", "on":
+	default:
+
+		severity, err := ParseGLDebugSeverity(value)
+
+		if err != nil {
+			Log.GL.Warn("Ignoring VOXELRPG_GL_DEBUG", "error", err)
+			return settings
+		}
+
+		settings.MinSeverity = severity
+
+	}
+
+	settings.Enabled = true
+	settings.PanicOnHigh = breakValue == "1"
+
+	return settings
+
+}
+
+func ParseGLDebugSeverity(name string) (GLDebugSeverity, error) {
+
+	for severity, severityName := range glDebugSeverityNames {
+		if strings.EqualFold(name, severityName) {
+			return GLDebugSeverity(severity), nil
+		}
+	}
+
+	return GLDebugLow, fmt.Errorf("unknown GL debug severity %q", name)
+
+}
+
+// setup installs the message callback once the context is loaded, debug output is core in the
+// GL 4.6 context NewGLContext requires
+func (d *GLDebugSettings) setup() {
+
+	if !d.Enabled {
+		return
+	}
+
+	var flags int32
+	gl.GetIntegerv(gl.CONTEXT_FLAGS, &flags)
+
+	if flags&gl.CONTEXT_FLAG_DEBUG_BIT == 0 {
+		Log.GL.Warn("Not a debug context, the driver may report fewer messages")
+	}
+
+	// Synchronous output calls back on the thread that made the GL call, so a panic points at it
+
+	gl.Enable(gl.DEBUG_OUTPUT)
+	gl.Enable(gl.DEBUG_OUTPUT_SYNCHRONOUS)
+
+	gl.DebugMessageCallback(d.message, nil)
+
+	for severity := GLDebugNotification; severity <= GLDebugHigh; severity++ {
+		gl.DebugMessageControl(gl.DONT_CARE, gl.DONT_CARE, glDebugSeverityEnum(severity), 0, nil, severity >= d.MinSeverity)
+	}
+
+	GPU.LabelsEnabled = true
+
+	Log.GL.Info("Debug output enabled", "minSeverity", d.MinSeverity, "panicOnHigh", d.PanicOnHigh)
+
+}
+
+func (d *GLDebugSettings) message(source uint32, gltype uint32, id uint32, severity uint32, length int32, message string, userParam unsafe.Pointer) {
+
+	level := glDebugSeverity(severity)
+
+	logLevel := Log.LevelDebug
+
+	switch level {
+	case GLDebugHigh:
+		logLevel = Log.LevelError
+	case GLDebugMedium:
+		logLevel = Log.LevelWarn
+	case GLDebugLow:
+		logLevel = Log.LevelInfo
+	}
+
+	message = strings.TrimSpace(message)
+
+	glDebugLog.Log(logLevel, message,
+		"source", glDebugSourceName(source),
+		"type", glDebugTypeName(gltype),
+		"id", id,
+		"severity", level,
+	)
+
+	if level == GLDebugHigh && d.PanicOnHigh {
+		panic(fmt.Sprintf("GL %v %v: %v", glDebugSourceName(source), glDebugTypeName(gltype), message))
+	}
+
+}
+
+/* -- [[ Decoding ]] -- */
+
+func glDebugSeverity(severity uint32) GLDebugSeverity {
+
+	switch severity {
+	case gl.DEBUG_SEVERITY_HIGH:
+		return GLDebugHigh
+	case gl.DEBUG_SEVERITY_MEDIUM:
+		return GLDebugMedium
+	case gl.DEBUG_SEVERITY_LOW:
+		return GLDebugLow
+	default:
+		return GLDebugNotification
+	}
+
+}
+
+func glDebugSeverityEnum(severity GLDebugSeverity) uint32 {
+
+	switch severity {
+	case GLDebugHigh:
+		return gl.DEBUG_SEVERITY_HIGH
+	case GLDebugMedium:
+		return gl.DEBUG_SEVERITY_MEDIUM
+	case GLDebugLow:
+		return gl.DEBUG_SEVERITY_LOW
+	default:
+		return gl.DEBUG_SEVERITY_NOTIFICATION
+	}
+
+}
+
+func glDebugSourceName(source uint32) string {
+
+	switch source {
+	case gl.DEBUG_SOURCE_API:
+		return "api"
+	case gl.DEBUG_SOURCE_WINDOW_SYSTEM:
+		return "window system"
+	case gl.DEBUG_SOURCE_SHADER_COMPILER:
+		return "shader compiler"
+	case gl.DEBUG_SOURCE_THIRD_PARTY:
+		return "third party"
+	case gl.DEBUG_SOURCE_APPLICATION:
+		return "application"
+	default:
+		return "other"
+	}
+
+}
+
+func glDebugTypeName(gltype uint32) string {
+
+	switch gltype {
+	case gl.DEBUG_TYPE_ERROR:
+		return "error"
+	case gl.DEBUG_TYPE_DEPRECATED_BEHAVIOR:
+		return "deprecated"
+	case gl.DEBUG_TYPE_UNDEFINED_BEHAVIOR:
+		return "undefined behavior"
+	case gl.DEBUG_TYPE_PORTABILITY:
+		return "portability"
+	case gl.DEBUG_TYPE_PERFORMANCE:
+		return "performance"
+	case gl.DEBUG_TYPE_MARKER:
+		return "marker"
+	case gl.DEBUG_TYPE_PUSH_GROUP:
+		return "push group"
+	case gl.DEBUG_TYPE_POP_GROUP:
+		return "pop group"
+	default:
+		return "other"
+	}
+
+}
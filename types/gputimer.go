@@ -0,0 +1,239 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	Log "VoxelRPG/logging"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+/* -- [[ GPU Timing ]] -- */
+
+// Scopes are measured with a pair of timestamp queries, so they may nest. Every scope has a
+// ring of query pairs and results are collected a few frames later once the GPU has caught
+// up, reading them never waits. A scope whose ring slot is still in flight skips that frame.
+
+const gpuTimerLatency = 3 // Frames a query has to finish before its slot is reused
+
+type gpuTimerSlot struct {
+	queries [2]uint32 // Begin and end timestamps
+	pending bool
+	frame   int
+}
+
+type GPUTimer struct {
+	Name  string
+	Stats TimingStats
+
+	slots [gpuTimerLatency]gpuTimerSlot
+}
+
+type GPUTimers struct {
+	Enabled bool
+
+	ReportInterval float64 // Seconds between performance reports in the log, 0 to disable
+
+	timers map[string]*GPUTimer
+	order  []string
+
+	open  []*gpuTimerSlot // Scopes begun and not yet ended, nil where a frame was skipped
+	frame int
+
+	lastReport float64
+}
+
+var GPUProfiler = &GPUTimers{
+	Enabled: true,
+
+	ReportInterval: 5,
+
+	timers: map[string]*GPUTimer{},
+}
+
+// FrameTimes is the CPU time between frames, recorded by the render loop
+var FrameTimes TimingStats
+
+func (g *GPUTimers) timer(name string) *GPUTimer {
+
+	timer := g.timers[name]
+
+	if timer != nil {
+		return timer
+	}
+
+	timer = &GPUTimer{Name: name}
+
+	for i := range timer.slots {
+		gl.GenQueries(2, &timer.slots[i].queries[0])
+	}
+
+	g.timers[name] = timer
+	g.order = append(g.order, name)
+
+	return timer
+
+}
+
+// Delete frees every query, scopes measured afterwards start with fresh statistics
+func (g *GPUTimers) Delete() {
+
+	for _, timer := range g.timers {
+		for i := range timer.slots {
+			gl.DeleteQueries(2, &timer.slots[i].queries[0])
+		}
+	}
+
+	g.timers = map[string]*GPUTimer{}
+	g.order = nil
+	g.open = nil
+
+}
+
+func (g *GPUTimers) Begin(name string) {
+
+	if !g.Enabled {
+		g.open = append(g.open, nil)
+		return
+	}
+
+	slot := &g.timer(name).slots[g.frame%gpuTimerLatency]
+
+	// Still waiting on an older frame, or already measured this frame (tiled screenshots)
+
+	if slot.pending {
+		g.open = append(g.open, nil)
+		return
+	}
+
+	gl.QueryCounter(slot.queries[0], gl.TIMESTAMP)
+
+	slot.frame = g.frame
+	g.open = append(g.open, slot)
+
+}
+
+func (g *GPUTimers) End() {
+
+	if len(g.open) == 0 {
+		Log.GL.Warn("GPU timer ended without a matching Begin")
+		return
+	}
+
+	slot := g.open[len(g.open)-1]
+	g.open = g.open[:len(g.open)-1]
+
+	if slot == nil {
+		return
+	}
+
+	gl.QueryCounter(slot.queries[1], gl.TIMESTAMP)
+	slot.pending = true
+
+}
+
+// Frame collects the results that have become available, called once at the start of every frame
+func (g *GPUTimers) Frame() {
+
+	g.frame++
+
+	for _, name := range g.order {
+
+		timer := g.timers[name]
+
+		for i := range timer.slots {
+
+			slot := &timer.slots[i]
+
+			if !slot.pending {
+				continue
+			}
+
+			var available int32
+			gl.GetQueryObjectiv(slot.queries[1], gl.QUERY_RESULT_AVAILABLE, &available)
+
+			if available == 0 {
+				continue
+			}
+
+			var begin, end uint64
+			gl.GetQueryObjectui64v(slot.queries[0], gl.QUERY_RESULT, &begin)
+			gl.GetQueryObjectui64v(slot.queries[1], gl.QUERY_RESULT, &end)
+
+			timer.Stats.Add(queryMilliseconds(begin, end))
+			slot.pending = false
+
+		}
+
+	}
+
+}
+
+// queryMilliseconds is the time between two timestamp query results, which are in nanoseconds
+func queryMilliseconds(begin, end uint64) float64 {
+	return float64(end-begin) / 1e6
+}
+
+// Stats returns the rolling statistics of a scope, false if it was never measured
+func (g *GPUTimers) Stats(name string) (TimingSummary, bool) {
+
+	timer := g.timers[name]
+
+	if timer == nil || timer.Stats.count == 0 {
+		return TimingSummary{}, false
+	}
+
+	return timer.Stats.Summary(), true
+
+}
+
+// Summaries returns the statistics of every scope measured so far
+func (g *GPUTimers) Summaries() map[string]TimingSummary {
+
+	output := map[string]TimingSummary{}
+
+	for _, name := range g.order {
+		if stats, ok := g.Stats(name); ok {
+			output[name] = stats
+		}
+	}
+
+	return output
+
+}
+
+func (g *GPUTimers) Report() string {
+
+	var report strings.Builder
+
+	frame := FrameTimes.Summary()
+
+	if frame.Avg > 0 {
+		fmt.Fprintf(&report, "Frame: %.1f fps (%v)", 1000/frame.Avg, frame)
+	}
+
+	for _, name := range g.order {
+
+		if stats, ok := g.Stats(name); ok {
+			fmt.Fprintf(&report, "\n  GPU %-10v %v", name, stats)
+		}
+
+	}
+
+	return report.String()
+
+}
+
+// ReportIfDue logs the report every ReportInterval seconds
+func (g *GPUTimers) ReportIfDue(now float64) {
+
+	if g.ReportInterval <= 0 || now-g.lastReport < g.ReportInterval {
+		return
+	}
+
+	g.lastReport = now
+
+	Log.GL.Info(g.Report())
+
+}
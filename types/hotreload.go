@@ -0,0 +1,134 @@
+package types
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	Log "VoxelRPG/logging"
+	World "VoxelRPG/world"
+)
+
+/* -- [[ Shader Hot Reload ]] -- */
+
+type ShaderReloader struct {
+	Enabled      bool
+	PollInterval float64 // Seconds between checking the shader files for changes
+
+	modified map[string]time.Time
+	nextPoll float64
+}
+
+var ShaderWatcher = &ShaderReloader{
+	Enabled:      true,
+	PollInterval: 0.5,
+}
+
+func (r *ShaderReloader) scan() map[string]time.Time {
+
+	modified := map[string]time.Time{}
+
+	// Watched recursively, so edits to included files reload too
+
+	filepath.WalkDir(ShaderOverrideDir, func(file string, entry fs.DirEntry, err error) error {
+
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+
+		if err != nil {
+			return nil
+		}
+
+		modified[file] = info.ModTime()
+
+		return nil
+
+	})
+
+	return modified
+
+}
+
+// Snapshot remembers the current modification times, so only later edits trigger a reload
+func (r *ShaderReloader) Snapshot() {
+
+	r.modified = r.scan()
+
+}
+
+func (r *ShaderReloader) changed() []string {
+
+	var changed []string
+
+	current := r.scan()
+
+	for file, modTime := range current {
+
+		if last, ok := r.modified[file]; !ok || !last.Equal(modTime) {
+			changed = append(changed, file)
+		}
+
+	}
+
+	r.modified = current
+
+	return changed
+
+}
+
+// Poll must be called from the thread owning the OpenGL context
+func (r *ShaderReloader) Poll(now float64) {
+
+	// Embedded shaders never change, only the override directory is watched
+
+	if !r.Enabled || ShaderOverrideDir == "" || now < r.nextPoll {
+		return
+	}
+
+	r.nextPoll = now + r.PollInterval
+
+	if r.modified == nil {
+		r.Snapshot()
+		return
+	}
+
+	changed := r.changed()
+
+	if len(changed) == 0 {
+		return
+	}
+
+	Log.Shader.Info("Shaders changed, reloading", "files", changed)
+
+	if err := ReloadShaders(); err != nil {
+		Log.Shader.Error("Shader reload failed, keeping previous shaders:\n" + err.Error())
+	}
+
+}
+
+// ReloadShaders relinks every program, keeping the old ones running and returning a *ShaderError
+// if anything fails to compile
+func ReloadShaders() error {
+
+	programs, err := buildShaderPrograms()
+
+	if err != nil {
+		return err
+	}
+
+	swapShaderPrograms(programs)
+
+	// Per frame uniforms are sent by OpenGLUpdate, the world ones only when the world changes
+
+	if err := World.MainWorld.SendGPUBuffers(); err != nil {
+		Log.World.Error("World buffers not refreshed after the shader reload", "error", err)
+	}
+
+	Log.Shader.Info("Shaders reloaded")
+
+	return nil
+
+}
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"math"
+
+	GPU "VoxelRPG/gpu"
+	World "VoxelRPG/world"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type LightingMode int32
+
+const (
+	LightingRayTraced LightingMode = iota // Shadow rays towards the sun and every nearby light
+	LightingFloodFill                     // Sky and block light propagated on the CPU, no extra rays
+)
+
+type Lighting struct {
+	Mode LightingMode
+
+	SunDirection mgl32.Vec3 // Direction pointing towards the sun
+	SunColor     mgl32.Vec3
+	AmbientColor mgl32.Vec3
+
+	BlockLightColor mgl32.Vec3 // Colour of full strength block light in flood fill mode
+
+	ShadowSamples  int32   // 0 disables shadow rays, 1 is a hard shadow, >1 jitters the rays for soft shadows
+	ShadowSoftness float32 // Radius of the jitter cone used for soft shadows
+
+	AOQuality  World.AOQuality
+	AOStrength float32 // 0 disables the darkening, 1 applies the full occlusion
+
+	DayLength float32 // Seconds for a full day cycle, 0 keeps the sun at SunDirection
+
+	sunDirection mgl32.Vec3
+	sunColor     mgl32.Vec3
+	daylight     float32
+}
+
+var WorldLighting = NewLighting()
+
+func NewLighting() *Lighting {
+
+	return &Lighting{
+		SunDirection: mgl32.Vec3{0.4, 0.8, 0.3}.Normalize(),
+		SunColor:     mgl32.Vec3{1.0, 0.95, 0.85},
+		AmbientColor: mgl32.Vec3{0.25, 0.28, 0.35},
+
+		BlockLightColor: mgl32.Vec3{1.0, 0.75, 0.45},
+
+		ShadowSamples:  1,
+		ShadowSoftness: 0.05,
+
+		AOQuality:  World.AOHigh,
+		AOStrength: 0.8,
+
+		DayLength: 0,
+	}
+
+}
+
+/* -- [[ Time of day ]] -- */
+
+func (l *Lighting) Update(time float32) {
+
+	l.sunDirection = l.SunDirection.Normalize()
+	l.sunColor = l.SunColor
+	l.daylight = 1
+
+	if l.DayLength <= 0 {
+		return
+	}
+
+	// Rotate the sun around the Z axis, starting at sunrise
+
+	angle := 2 * math.Pi * float64(time/l.DayLength)
+
+	l.sunDirection = mgl32.Vec3{
+		float32(math.Cos(angle)),
+		float32(math.Sin(angle)),
+		l.SunDirection.Z(),
+	}.Normalize()
+
+	// Fade the sun out as it sets below the horizon
+
+	l.daylight = float32(ClampF64(float64(l.sunDirection.Y())*4, 0, 1))
+	l.sunColor = l.SunColor.Mul(l.daylight)
+
+}
+
+// Daylight is 1 while the sun is up and fades to 0 as it sets
+func (l *Lighting) Daylight() float32 {
+	return l.daylight
+}
+
+func (l *Lighting) Upload(shaderProgram *GPU.Program) {
+
+	shaderProgram.SetInt("lightingMode", int32(l.Mode))
+	shaderProgram.SetVec3("blockLightColor", l.BlockLightColor)
+	shaderProgram.SetVec3("sunDirection", l.sunDirection)
+	shaderProgram.SetVec3("sunColor", l.sunColor)
+	shaderProgram.SetVec3("ambientColor", l.AmbientColor)
+	shaderProgram.SetInt("shadowSamples", l.ShadowSamples)
+	shaderProgram.SetFloat("shadowSoftness", l.ShadowSoftness)
+	shaderProgram.SetInt("aoQuality", int32(l.AOQuality))
+	shaderProgram.SetFloat("aoStrength", l.AOStrength)
+
+}
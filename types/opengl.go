@@ -1,7 +1,7 @@
 package types
 
 import (
-	"fmt"
+	"time"
 	"unsafe"
 
 	"github.com/go-gl/gl/v4.6-core/gl"
@@ -9,41 +9,64 @@ import (
 	"github.com/go-gl/mathgl/mgl32"
 
 	Client "VoxelRPG/client"
+	GPU "VoxelRPG/gpu"
 	Log "VoxelRPG/logging"
+	Profiling "VoxelRPG/profiling"
 	World "VoxelRPG/world"
 )
 
 var (
-	screenVAO           uint32
-	shaderProgram       uint32
-	screenShaderProgram uint32
+	screenVAO             uint32
+	screenVBO             uint32
+	shaderProgram         *GPU.Program
+	screenShaderProgram   *GPU.Program
+	temporalShaderProgram *GPU.Program
 
-	fbo        uint32
-	fboTexture uint32
+	fbo             uint32
+	fboTexture      uint32
+	fboDepthTexture uint32 // Distance to the first surface per pixel, for temporal reprojection
+
+	debugResultSSBO uint32
 
 	FOV   float32
 	ZNear float32
 	ZFar  float32
 
 	Scaledown float32
+
+	MaxTransparencyDepth int32 = 8
+	MaxLightsPerHit      int32 = 4
 )
 
 func NewGLContext() error {
 
-	profiler := ProfilerStart("opengl")
+	profiler := Profiling.Start("gl.init")
 
-	Log.NewLog("OpenGL(Glow) Context - Creating..")
+	Log.GL.Info("OpenGL(Glow) Context - Creating..")
 
 	err := gl.Init()
 
 	if err != nil {
-		Log.NewLog("Could not initialize OpenGL")
+		Log.GL.Error("Could not initialize OpenGL", "error", err)
 		return err
 	}
 
-	TimeTook := profiler.EndProfiler(profiler)
+	// Drivers may hand out an older context than asked for instead of failing
+
+	var major, minor int32
+
+	gl.GetIntegerv(gl.MAJOR_VERSION, &major)
+	gl.GetIntegerv(gl.MINOR_VERSION, &minor)
+
+	if major < GLVersionMajor || (major == GLVersionMajor && minor < GLVersionMinor) {
+		return &ContextError{Major: GLVersionMajor, Minor: GLVersionMinor, Version: gl.GoStr(gl.GetString(gl.VERSION))}
+	}
+
+	GLDebug.setup()
+
+	TimeTook := profiler.End()
 
-	Log.NewLog("OpenGL(Glow) Context - Created ( Time:", TimeTook, ")\n")
+	Log.GL.Info("OpenGL(Glow) Context - Created", "time", TimeTook)
 
 	return nil
 
@@ -55,138 +78,177 @@ func setupPerspectives(window *WindowBuilder, client *Client.ClientContext) {
 	ZNear = 0.1
 	ZFar = 1000.0
 
-	projection := mgl32.Perspective(mgl32.DegToRad(FOV), float32(window.Width)/float32(window.Height), ZNear, ZFar)
-	projectionUniform := gl.GetUniformLocation(shaderProgram, gl.Str("projection\x00"))
-	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
-
-	cam := client.Camera
-
-	camera := mgl32.LookAtV(
-		mgl32.Vec3{cam.Pos[0], cam.Pos[1], cam.Pos[2]},
-		mgl32.Vec3{cam.Pos[0] + cam.Front[0], cam.Pos[1] + cam.Front[1], cam.Pos[2] + cam.Front[2]},
-		mgl32.Vec3{0, 1, 0},
-	)
-
-	cameraUniform := gl.GetUniformLocation(shaderProgram, gl.Str("camera\x00"))
-	gl.UniformMatrix4fv(cameraUniform, 1, false, &camera[0])
-	model := mgl32.Ident4()
-	modelUniform := gl.GetUniformLocation(shaderProgram, gl.Str("model\x00"))
-	gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+	// The camera itself is uploaded every frame by OpenGLUpdate
 
 }
 
-func setupShaders() {
+func newProgram(name string, vertexFile string, fragmentFile string) (*GPU.Program, error) {
 
-	/* --[[ Main Screen Shader ]] */
+	vertex_shader, err := NewShader(vertexFile, gl.VERTEX_SHADER)
 
-	vertex_shader, err := NewShader("octree_traverse.vert", gl.VERTEX_SHADER)
-	CheckError(err)
+	if err != nil {
+		return nil, err
+	}
 
-	fragment_shader, err := NewShader("octree_traverse.frag", gl.FRAGMENT_SHADER)
-	CheckError(err)
+	fragment_shader, err := NewShader(fragmentFile, gl.FRAGMENT_SHADER)
+
+	if err != nil {
+		gl.DeleteShader(vertex_shader)
+		return nil, err
+	}
 
 	shaders := []uint32{
 		vertex_shader,
 		fragment_shader,
 	}
 
-	shaderProgram, err = NewShaderProgram(shaders)
-	CheckError(err)
+	program, err := NewShaderProgram(shaders)
 
-	/* --[[ Upscaled Texture Shader ]] */
+	if err != nil {
 
-	vertex_shader, err = NewShader("screen.vert", gl.VERTEX_SHADER)
-	CheckError(err)
+		for _, shader := range shaders {
+			gl.DeleteShader(shader)
+		}
 
-	fragment_shader, err = NewShader("screen.frag", gl.FRAGMENT_SHADER)
-	CheckError(err)
+		return nil, err
 
-	shaders = []uint32{
-		vertex_shader,
-		fragment_shader,
 	}
 
-	screenShaderProgram, err = NewShaderProgram(shaders)
-	CheckError(err)
+	return GPU.NewProgram(program, name), nil
 
 }
 
-func setupBuffers(window *WindowBuilder) {
+// Every program is rebuilt together, so a failed hot reload never leaves a mix of old and new
+var programSources = []struct {
+	target       **GPU.Program
+	name         string
+	vertexFile   string
+	fragmentFile string
+}{
+	{&shaderProgram, "octree_traverse", "octree_traverse.vert", "octree_traverse.frag"}, // Main Screen Shader
+	{&screenShaderProgram, "screen", "screen.vert", "screen.frag"},                      // Upscaled Texture Shader
+	{&temporalShaderProgram, "temporal", "screen.vert", "temporal.frag"},                // Temporal Accumulation
+	{&postTonemapProgram, "post_tonemap", "screen.vert", "post_tonemap.frag"},           // Post Processing Passes
+	{&postGammaProgram, "post_gamma", "screen.vert", "post_gamma.frag"},
+	{&postLUTProgram, "post_lut", "screen.vert", "post_lut.frag"},
+	{&postFXAAProgram, "post_fxaa", "screen.vert", "post_fxaa.frag"},
+	{&postVignetteProgram, "post_vignette", "screen.vert", "post_vignette.frag"},
+}
+
+func buildShaderPrograms() ([]*GPU.Program, error) {
+
+	programs := make([]*GPU.Program, 0, len(programSources))
+
+	for _, source := range programSources {
+
+		program, err := newProgram(source.name, source.vertexFile, source.fragmentFile)
+
+		if shaderErr, ok := err.(*ShaderError); ok {
+			shaderErr.Program = source.name
+		}
+
+		if err != nil {
+
+			for _, built := range programs {
+				built.Delete()
+			}
+
+			return nil, err
+
+		}
+
+		programs = append(programs, program)
+
+	}
+
+	return programs, nil
+
+}
+
+func swapShaderPrograms(programs []*GPU.Program) {
+
+	for i, source := range programSources {
+
+		if *source.target != nil {
+			(*source.target).Delete()
+		}
+
+		*source.target = programs[i]
+
+	}
+
+}
+
+func setupShaders() error {
+
+	programs, err := buildShaderPrograms()
+
+	if err != nil {
+		return err
+	}
+
+	swapShaderPrograms(programs)
+
+	ShaderWatcher.Snapshot()
+
+	return nil
+
+}
+
+func setupBuffers(window *WindowBuilder) error {
 
 	/* --[[ Create Shaders ]] */
 
-	setupShaders()
+	if ShaderOverrideDir != "" {
+		Log.Shader.Info("Loading shaders from the override directory before the embedded ones", "dir", ShaderOverrideDir)
+	}
+
+	if err := setupShaders(); err != nil {
+		return &SetupError{Stage: "shaders", Err: err}
+	}
 
 	/* --[[ Create VAO (Vertex Array Object) ]] */
 
-	gl.UseProgram(shaderProgram)
+	shaderProgram.Use()
 
 	screenVAO = NewVertexArray(1)
 
-	NewBufferObject(1, gl.ARRAY_BUFFER, func(_ uint32) {
+	screenVBO = NewBufferObject(1, gl.ARRAY_BUFFER, func(_ uint32) {
 		gl.BufferData(gl.ARRAY_BUFFER, len(fullscreenQuadVertices)*4, gl.Ptr(fullscreenQuadVertices), gl.STATIC_DRAW)
 	})
 
-	resUniform := gl.GetUniformLocation(shaderProgram, gl.Str("iResolution\x00"))
-	gl.Uniform2f(resUniform, float32(window.Width), float32(window.Height))
+	GPU.Label(gl.VERTEX_ARRAY, screenVAO, "Screen Quad VAO")
+	GPU.Label(gl.BUFFER, screenVBO, "Screen Quad VBO")
+
+	shaderProgram.SetVec2("iResolution", mgl32.Vec2{float32(window.Width), float32(window.Height)})
 
-	vertAttrib := uint32(gl.GetAttribLocation(shaderProgram, gl.Str("vert\x00")))
+	vertAttrib := uint32(gl.GetAttribLocation(shaderProgram.ID, gl.Str("vert\x00")))
 	gl.VertexAttribPointerWithOffset(vertAttrib, 2, gl.FLOAT, false, 4*4, 0)
 	gl.EnableVertexAttribArray(vertAttrib)
 
 	// Set offset from our vertex buffer object to allow it to read verticies correctly
 
-	texCoordAttrib := uint32(gl.GetAttribLocation(shaderProgram, gl.Str("vertTexCoord\x00")))
+	texCoordAttrib := uint32(gl.GetAttribLocation(shaderProgram.ID, gl.Str("vertTexCoord\x00")))
 	gl.EnableVertexAttribArray(texCoordAttrib)
 	gl.VertexAttribPointerWithOffset(texCoordAttrib, 2, gl.FLOAT, false, 4*4, uintptr(2*4))
 
 	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
 	gl.BindVertexArray(0)
 
-	/* --[[ Generate World Info SSBO Buffer ]] */
-
-	if World.MainWorld.CombinedSSBO == 0 {
-		gl.GenBuffers(1, &World.MainWorld.CombinedSSBO)
-		if World.MainWorld.CombinedSSBO == 0 {
-			Log.NewLog("Failed to generate Combined SSBO")
-		}
-	}
-
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, World.MainWorld.CombinedSSBO)
-	BufferSize := (World.NodesRequired * (World.TOTAL_RENDERED_CHUNKS)) * int(World.OctreeNodeByteSize)
-	fmt.Printf("Allocating buffer size: %d bytes\n", BufferSize)
-
-	gl.BufferData(
-		gl.SHADER_STORAGE_BUFFER,
-		BufferSize,
-		nil,
-		gl.DYNAMIC_DRAW,
-	)
-
-	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 0, World.MainWorld.CombinedSSBO)
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
-
-	/* --[[ Setup Octtree ]] */
-
-	World.MainWorld.Populate(shaderProgram)
-	World.MainWorld.Update(shaderProgram)
-
-	Log.NewLog("Total Voxel Count:", ((World.RENDER_DISTANCE * World.RENDER_DISTANCE * World.RENDER_DISTANCE) * (World.CHUNK_SIZE * World.CHUNK_SIZE * World.CHUNK_SIZE)))
-
-	Log.NewLog("Total Byte Count:", ((World.RENDER_DISTANCE * World.RENDER_DISTANCE * World.RENDER_DISTANCE) * World.OctreeNodeByteSize))
-
 	/* --[[ Frame Buffer Object for rendering world at low resolutions ]] */
 
-	Scaledown = 1
+	Scaledown = 1 / DynamicResolution.Scale
 
-	gl.UseProgram(screenShaderProgram)
+	screenShaderProgram.Use()
 
 	gl.GenFramebuffers(1, &fbo)
 	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	GPU.Label(gl.FRAMEBUFFER, fbo, "Scene FBO")
 
 	gl.GenTextures(1, &fboTexture)
 	gl.BindTexture(gl.TEXTURE_2D, fboTexture)
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, int32(float32(window.Width)/Scaledown), int32(float32(window.Height)/Scaledown), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	GPU.Label(gl.TEXTURE, fboTexture, "Scene Color")
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA16F, int32(float32(window.Width)/Scaledown), int32(float32(window.Height)/Scaledown), 0, gl.RGBA, gl.FLOAT, nil)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
@@ -194,47 +256,160 @@ func setupBuffers(window *WindowBuilder) {
 
 	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, fboTexture, 0)
 
-	if gl.CheckFramebufferStatus(gl.FRAMEBUFFER) != gl.FRAMEBUFFER_COMPLETE {
-		Log.NewLog("ERROR: Framebuffer is not complete")
+	fboDepthTexture = newRenderTexture(gl.R32F, gl.RED, gl.NEAREST, int32(float32(window.Width)/Scaledown), int32(float32(window.Height)/Scaledown))
+	GPU.Label(gl.TEXTURE, fboDepthTexture, "Scene Depth")
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT1, gl.TEXTURE_2D, fboDepthTexture, 0)
+
+	drawBuffers := []uint32{gl.COLOR_ATTACHMENT0, gl.COLOR_ATTACHMENT1}
+	gl.DrawBuffers(int32(len(drawBuffers)), &drawBuffers[0])
+
+	if err := checkFramebuffer("scene"); err != nil {
+		return &SetupError{Stage: "framebuffer", Err: err}
 	}
 
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
 
+	/* --[[ Generate World Info SSBO Buffer ]] */
+
+	// The shaders read these buffers with the std430 layout generated from the Go structs
+
+	if err := World.CheckGPULayouts(); err != nil {
+		return &SetupError{Stage: "GPU layouts", Err: err}
+	}
+
+	if World.MainWorld.Backend == nil {
+		World.MainWorld.Backend = NewGLBackend(&shaderProgram, fbo, screenVAO, WorldLighting, WorldAtmosphere)
+	}
+
+	Nodes := World.NodesRequired * World.TOTAL_RENDERED_CHUNKS
+	Log.GL.Info("Allocating octree buffer", "bytes", Nodes*World.OctreeNodeByteSize)
+
+	if err := World.MainWorld.AllocateNodes(Nodes); err != nil {
+		return &SetupError{Stage: "octree buffer", Err: err}
+	}
+
+	/* --[[ Setup Octtree ]] */
+
+	World.MainWorld.FloodFillLighting = WorldLighting.Mode == LightingFloodFill
+
+	if err := World.MainWorld.Populate(); err != nil {
+		return &SetupError{Stage: "world generation", Err: err}
+	}
+
+	if err := World.MainWorld.Update(); err != nil {
+		return &SetupError{Stage: "world upload", Err: err}
+	}
+
+	Log.World.Info("World loaded",
+		"voxels", (World.RENDER_DISTANCE*World.RENDER_DISTANCE*World.RENDER_DISTANCE)*(World.CHUNK_SIZE*World.CHUNK_SIZE*World.CHUNK_SIZE),
+		"rootBytes", (World.RENDER_DISTANCE*World.RENDER_DISTANCE*World.RENDER_DISTANCE)*World.OctreeNodeByteSize,
+	)
+
+	/* --[[ Post Processing ]] */
+
+	setupPostProcessing()
+
 	if World.DEBUG_MODE == false {
-		return
+		return nil
 	}
 
 	// Debugging
 	var result [1]World.ChunkInfo
-	gl.GenBuffers(1, &World.MainWorld.DebugResultSSBO)
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, World.MainWorld.DebugResultSSBO)
+	gl.GenBuffers(1, &debugResultSSBO)
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, debugResultSSBO)
+	GPU.Label(gl.BUFFER, debugResultSSBO, "Debug Result SSBO")
 	gl.BufferData(
 		gl.SHADER_STORAGE_BUFFER,
 		int(unsafe.Sizeof(result[0])),
 		nil, gl.DYNAMIC_DRAW,
 	)
-	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 3, World.MainWorld.DebugResultSSBO)
+	GPU.BindStorageBuffer(GPU.BindingDebug, debugResultSSBO)
 	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
 
+	return nil
+
 }
 
-func OpenGLSetup(window *WindowBuilder, client *Client.ClientContext) {
+// debugMapLog keeps a failing debug readback from logging every frame
+var debugMapLog = Log.GL.Every(5 * time.Second)
 
-	Log.NewLog("OpenGL - Setup...")
+func OpenGLSetup(window *WindowBuilder, client *Client.ClientContext) error {
+
+	Log.GL.Info("OpenGL - Setup...")
 
 	gl.Enable(gl.DEPTH_TEST)
 	gl.Enable(gl.CULL_FACE)
 	gl.DepthFunc(gl.LESS)
 	gl.ClearColor(0.3, 0.3, 0.3, 1.0)
 
-	setupBuffers(window)
+	if err := setupBuffers(window); err != nil {
+		return err
+	}
 
 	/* --[[ Setup Perspective ]] */
 
 	setupPerspectives(window, client)
 	OnWindowResize(glfw.GetCurrentContext(), window.Width, window.Height, window)
 
-	Log.NewLog("OpenGL Setup - Complete\n")
+	Log.GL.Info("OpenGL Setup - Complete")
+
+	return nil
+
+}
+
+// OpenGLShutdown saves the world's pending edits and deletes every GL object the engine made,
+// called on the GL thread after the last frame
+func OpenGLShutdown() {
+
+	Log.GL.Info("OpenGL - Shutting down..")
+
+	World.MainWorld.Close()
+
+	TemporalAA.Delete()
+	PostProcessing.Delete()
+	GPUProfiler.Delete()
+
+	textures := []*uint32{&fboTexture, &fboDepthTexture, &colorLUT}
+
+	for _, texture := range textures {
+
+		if *texture != 0 {
+			gl.DeleteTextures(1, texture)
+			*texture = 0
+		}
+
+	}
+
+	if fbo != 0 {
+		gl.DeleteFramebuffers(1, &fbo)
+		fbo = 0
+	}
+
+	if screenVBO != 0 {
+		gl.DeleteBuffers(1, &screenVBO)
+		screenVBO = 0
+	}
+
+	if debugResultSSBO != 0 {
+		gl.DeleteBuffers(1, &debugResultSSBO)
+		debugResultSSBO = 0
+	}
+
+	if screenVAO != 0 {
+		gl.DeleteVertexArrays(1, &screenVAO)
+		screenVAO = 0
+	}
+
+	for _, source := range programSources {
+
+		if *source.target != nil {
+			(*source.target).Delete()
+			*source.target = nil
+		}
+
+	}
+
+	Log.GL.Info("OpenGL - Shut down")
 
 }
 
@@ -247,15 +422,18 @@ func ResizeFramebuffer(fbo uint32, texture *uint32, width, height int) {
 	gl.TexImage2D(
 		gl.TEXTURE_2D,
 		0,
-		gl.RGBA8,
+		gl.RGBA16F,
 		scaledWidth,
 		scaledHeight,
 		0,
 		gl.RGBA,
-		gl.UNSIGNED_BYTE,
+		gl.FLOAT,
 		nil,
 	)
 
+	gl.BindTexture(gl.TEXTURE_2D, fboDepthTexture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.R32F, scaledWidth, scaledHeight, 0, gl.RED, gl.FLOAT, nil)
+
 	// Reattach texture to framebuffer
 	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
 	gl.FramebufferTexture2D(
@@ -265,21 +443,24 @@ func ResizeFramebuffer(fbo uint32, texture *uint32, width, height int) {
 		*texture,
 		0,
 	)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT1, gl.TEXTURE_2D, fboDepthTexture, 0)
 
 	// Check FBO completeness
-	if gl.CheckFramebufferStatus(gl.FRAMEBUFFER) != gl.FRAMEBUFFER_COMPLETE {
-		Log.NewLog("ERROR: Framebuffer is not complete after resize!")
+	if err := checkFramebuffer("scene"); err != nil {
+		Log.GL.Error("Framebuffer resize failed", "error", err)
 	}
 
 	// Unbind to avoid side effects
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	TemporalAA.Resize(scaledWidth, scaledHeight)
 }
 
 func OnWindowResize(w *glfw.Window, width int, height int, wBuild *WindowBuilder) {
 
 	// Change OpenGL viewport to the new window size
 
-	Log.NewLog("New Size - W:", width, "H:", height, "Aspect:", float32(wBuild.Width)/float32(wBuild.Height))
+	Log.GL.Info("Window resized", "width", width, "height", height, "aspect", float32(wBuild.Width)/float32(wBuild.Height))
 
 	gl.Viewport(0, 0, int32(width), int32(height))
 
@@ -287,13 +468,24 @@ func OnWindowResize(w *glfw.Window, width int, height int, wBuild *WindowBuilder
 	wBuild.Height = height
 
 	ResizeFramebuffer(fbo, &fboTexture, width, height)
+	PostProcessing.Resize(int32(width), int32(height))
 }
 
 func OpenGLFixedUpdate(window *glfw.Window, windowBuilder *WindowBuilder) {
 
 	// Always runs every 1/165 of a second
 
-	W, H := window.GetSize()
+	// Rendering works in pixels, which differ from window coordinates on scaled displays
+
+	W, H := window.GetFramebufferSize()
+
+	ShaderWatcher.Poll(glfw.GetTime())
+
+	// A minimized window has no framebuffer, keep the targets until it comes back
+
+	if W == 0 || H == 0 {
+		return
+	}
 
 	if windowBuilder.Width != W || windowBuilder.Height != H {
 
@@ -303,16 +495,60 @@ func OpenGLFixedUpdate(window *glfw.Window, windowBuilder *WindowBuilder) {
 
 }
 
+// renderScene raymarches the world into the FBO, viewport is the part of an image of the given resolution it covers
+func renderScene(cam *Client.Camera, view mgl32.Mat4, jitter mgl32.Vec2, time float32, width, height int32, resolution mgl32.Vec2, viewport mgl32.Vec4) {
+
+	// === Update World if required ===
+
+	GPUProfiler.Begin("upload")
+	World.MainWorld.FlushDirtyChunks()
+	GPUProfiler.End()
+
+	//World.MainWorld.UpdateIfNeeded(view, cam.Pos)
+
+	GPUProfiler.Begin("raymarch")
+
+	World.MainWorld.Backend.DrawFrame(World.FrameParams{
+		Width:      width,
+		Height:     height,
+		Resolution: resolution,
+		Viewport:   viewport,
+
+		InvView:   view.Inv(),
+		CameraPos: cam.Pos,
+		Jitter:    jitter,
+		Time:      time,
+
+		FOV:       FOV,
+		DebugView: DebugView,
+
+		MaxTransparencyDepth: MaxTransparencyDepth,
+		MaxLightsPerHit:      MaxLightsPerHit,
+	})
+
+	GPUProfiler.End()
+
+}
+
 func OpenGLUpdate(cam *Client.Camera, windowBuilder *WindowBuilder) {
 
 	//Log.NewLog("Camera Pos:", cam.Pos, "Chunk Pos:", World.GetCameraChunk(cam.Pos))
 
-	// === First Pass: Render raymarcher to half-resolution FBO ===
+	defer Profiling.Start("frame").End()
 
-	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
-	gl.Viewport(0, 0, int32(float32(windowBuilder.Width)/Scaledown), int32(float32(windowBuilder.Height)/Scaledown))
-	gl.UseProgram(shaderProgram)
-	gl.BindVertexArray(screenVAO)
+	Wall := glfw.GetTime()
+
+	GPUProfiler.Frame()
+
+	// Recordings run on their own clock, where frame times say nothing about performance
+
+	Now := Recording.Clock(Wall)
+
+	if !Recording.Active() {
+		DynamicResolution.Frame(Wall, windowBuilder)
+	}
+
+	Recording.ApplyCamera(cam)
 
 	// === Camera Setup ===
 
@@ -321,52 +557,48 @@ func OpenGLUpdate(cam *Client.Camera, windowBuilder *WindowBuilder) {
 		mgl32.Vec3{cam.Pos[0] + cam.Front[0], cam.Pos[1] + cam.Front[1], cam.Pos[2] + cam.Front[2]},
 		mgl32.Vec3{0, 1, 0},
 	)
-	invView := view.Inv()
 
-	projection := mgl32.Perspective(mgl32.DegToRad(FOV), float32(windowBuilder.Width)/Scaledown/float32(windowBuilder.Height)/Scaledown, ZNear, ZFar)
+	Time := float32(Now)
 
-	// === Uniform Uploads ===
+	WorldLighting.Update(Time)
 
-	loc := gl.GetUniformLocation(shaderProgram, gl.Str("invView\x00"))
+	// === Tiled screenshots render before the frame, which then draws over the last tile ===
 
-	gl.UniformMatrix4fv(loc, 1, false, &invView[0])
-	gl.UniformMatrix4fv(gl.GetUniformLocation(shaderProgram, gl.Str("projection\x00")), 1, false, &projection[0])
-	gl.Uniform3f(gl.GetUniformLocation(shaderProgram, gl.Str("camPos\x00")), cam.Pos[0], cam.Pos[1], cam.Pos[2])
-	gl.Uniform1f(gl.GetUniformLocation(shaderProgram, gl.Str("iTime\x00")), float32(glfw.GetTime()))
-	gl.Uniform2f(gl.GetUniformLocation(shaderProgram, gl.Str("iResolution\x00")), float32(windowBuilder.Width)/float32(Scaledown), float32(windowBuilder.Height)/float32(Scaledown))
-	gl.Uniform1f(gl.GetUniformLocation(shaderProgram, gl.Str("fov\x00")), FOV)
+	Screenshots.renderTiled(cam, view, Time, windowBuilder)
 
-	// === Bind SSBO ===
+	// === First Pass: Render raymarcher to the scaled down FBO ===
 
-	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 0, World.MainWorld.CombinedSSBO)
+	jitter := TemporalAA.JitterOffset()
 
-	// === Update World if required ===
+	scaledWidth := int32(float32(windowBuilder.Width) / Scaledown)
+	scaledHeight := int32(float32(windowBuilder.Height) / Scaledown)
 
-	//World.MainWorld.UpdateIfNeeded(shaderProgram, projection.Mul4(view), cam.Pos)
+	renderScene(cam, view, jitter, Time, scaledWidth, scaledHeight, mgl32.Vec2{float32(scaledWidth), float32(scaledHeight)}, mgl32.Vec4{0, 0, 1, 1})
 
-	// === Draw Fullscreen Quad ===
+	// === Second Pass: Blend with the reprojected history ===
 
-	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+	GPUProfiler.Begin("temporal")
+	output := TemporalAA.Resolve(view, cam.Pos, jitter)
+	GPUProfiler.End()
 
-	// === Second Pass: Blot to full-resolution screen ===
+	// === Third Pass: Upscale and post process to the screen ===
 
-	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
-	gl.Viewport(0, 0, int32(windowBuilder.Width), int32(windowBuilder.Height))
-	gl.UseProgram(screenShaderProgram)
-	gl.BindVertexArray(screenVAO)
-
-	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, fboTexture)
-	gl.Uniform1i(gl.GetUniformLocation(screenShaderProgram, gl.Str("tex\x00")), 0)
+	GPUProfiler.Begin("post")
+	PostProcessing.Execute(output)
+	GPUProfiler.End()
 
-	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+	Screenshots.capture(windowBuilder)
+	Recording.Capture(windowBuilder)
 
 	if World.DEBUG_MODE == true {
 
-		gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, World.MainWorld.DebugResultSSBO)
+		gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, debugResultSSBO)
 		ptr := gl.MapBuffer(gl.SHADER_STORAGE_BUFFER, gl.READ_ONLY)
+
 		if ptr == nil {
-			panic("Failed to map debug result SSBO")
+			debugMapLog.Error("Failed to map debug result SSBO, skipping the readback")
+			gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+			return
 		}
 
 		var outResult struct {
@@ -383,10 +615,7 @@ func OpenGLUpdate(cam *Client.Camera, windowBuilder *WindowBuilder) {
 
 		if outResult.z != 1000 {
 
-			fmt.Printf("\n")
-
-			fmt.Println("Debug result: Data:", outResult)
-			fmt.Printf("\n")
+			Log.GL.Debug("Debug result", "data", outResult)
 
 		}
 
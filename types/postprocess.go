@@ -0,0 +1,463 @@
+package types
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"strconv"
+	"strings"
+
+	GPU "VoxelRPG/gpu"
+	Log "VoxelRPG/logging"
+	World "VoxelRPG/world"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+/* -- [[ Post Processing ]] -- */
+
+// The raymarcher and temporal pass write HDR colour at the internal resolution. The post chain
+// runs every enabled pass in order over window sized RGBA16F targets, the last one drawing to
+// the screen. The first pass upscales, so every later pass works at window resolution.
+
+type Tonemapper int32
+
+const (
+	TonemapNone Tonemapper = iota
+	TonemapReinhard
+	TonemapACES
+)
+
+var tonemapperNames = [...]string{"none", "reinhard", "aces"}
+
+func (t Tonemapper) String() string {
+	return tonemapperNames[t]
+}
+
+type PostSettings struct {
+	Exposure   float32
+	Tonemapper Tonemapper
+
+	Gamma float32
+
+	VignetteStrength float32
+	VignetteRadius   float32
+
+	LUTFile     string // PNG of N slices of N x N laid out horizontally, empty for an identity LUT
+	LUTStrength float32
+}
+
+// Voxel colours are authored in display space, so gamma is off unless the scene is shaded in linear
+var PostConfig = &PostSettings{
+	Exposure:   1,
+	Tonemapper: TonemapACES,
+
+	Gamma: 2.2,
+
+	VignetteStrength: 0.35,
+	VignetteRadius:   0.6,
+
+	LUTStrength: 1,
+}
+
+var (
+	postTonemapProgram  *GPU.Program
+	postGammaProgram    *GPU.Program
+	postLUTProgram      *GPU.Program
+	postFXAAProgram     *GPU.Program
+	postVignetteProgram *GPU.Program
+
+	colorLUT uint32
+)
+
+type PostPass struct {
+	Name    string
+	Enabled bool
+
+	PerPixel bool // Only reads the pixel it writes, so it can run on one tile of a larger image
+
+	program **GPU.Program
+	setup   func(program *GPU.Program) // Uploads the pass uniforms, the input is always bound to tex
+}
+
+type postTarget struct {
+	fbo   uint32
+	color uint32
+}
+
+type RenderGraph struct {
+	Passes []*PostPass
+
+	targets [2]postTarget
+
+	width, height int32
+}
+
+var PostProcessing = &RenderGraph{
+	Passes: []*PostPass{
+		{Name: "upscale", Enabled: true, PerPixel: true, program: &screenShaderProgram, setup: func(program *GPU.Program) {
+			program.SetInt("upscaleFilter", int32(DynamicResolution.Filter))
+		}},
+		{Name: "tonemap", Enabled: true, PerPixel: true, program: &postTonemapProgram, setup: func(program *GPU.Program) {
+			// Debug view colours are passed through untouched
+
+			if DebugView != World.DebugViewOff {
+				program.SetFloat("exposure", 1)
+				program.SetInt("tonemapper", int32(TonemapNone))
+				return
+			}
+
+			program.SetFloat("exposure", PostConfig.Exposure)
+			program.SetInt("tonemapper", int32(PostConfig.Tonemapper))
+		}},
+		{Name: "gamma", Enabled: false, PerPixel: true, program: &postGammaProgram, setup: func(program *GPU.Program) {
+			program.SetFloat("gamma", PostConfig.Gamma)
+		}},
+		{Name: "lut", Enabled: false, PerPixel: true, program: &postLUTProgram, setup: func(program *GPU.Program) {
+			gl.ActiveTexture(gl.TEXTURE1)
+			gl.BindTexture(gl.TEXTURE_3D, colorLUT)
+			program.SetInt("lut", 1)
+			program.SetFloat("lutStrength", PostConfig.LUTStrength)
+		}},
+		{Name: "fxaa", Enabled: true, program: &postFXAAProgram},
+		{Name: "vignette", Enabled: false, program: &postVignetteProgram, setup: func(program *GPU.Program) {
+			program.SetFloat("vignetteStrength", PostConfig.VignetteStrength)
+			program.SetFloat("vignetteRadius", PostConfig.VignetteRadius)
+		}},
+	},
+}
+
+/* -- [[ Settings ]] -- */
+
+func ParseTonemapper(name string) (Tonemapper, error) {
+
+	for tonemapper, tonemapperName := range tonemapperNames {
+		if strings.EqualFold(name, tonemapperName) {
+			return Tonemapper(tonemapper), nil
+		}
+	}
+
+	return TonemapACES, fmt.Errorf("unknown tonemapper %q", name)
+
+}
+
+// envFloat reads a float setting, leaving the value alone when it is unset or not a number
+func envFloat(name string, value *float32) {
+
+	text := os.Getenv(name)
+
+	if text == "" {
+		return
+	}
+
+	parsed, err := strconv.ParseFloat(text, 32)
+
+	if err != nil {
+		Log.GL.Warn("Ignoring "+name, "error", err)
+		return
+	}
+
+	*value = float32(parsed)
+
+}
+
+// ApplyEnv reads VOXELRPG_POST_EXPOSURE, VOXELRPG_POST_TONEMAP, VOXELRPG_POST_GAMMA,
+// VOXELRPG_POST_VIGNETTE_STRENGTH, VOXELRPG_POST_VIGNETTE_RADIUS, VOXELRPG_POST_LUT and
+// VOXELRPG_POST_LUT_STRENGTH over the settings, it has to run before OpenGLSetup loads the LUT
+func (s *PostSettings) ApplyEnv() {
+
+	envFloat("VOXELRPG_POST_EXPOSURE", &s.Exposure)
+
+	if value := os.Getenv("VOXELRPG_POST_TONEMAP"); value != "" {
+
+		if tonemapper, err := ParseTonemapper(value); err != nil {
+			Log.GL.Warn("Ignoring VOXELRPG_POST_TONEMAP", "error", err)
+		} else {
+			s.Tonemapper = tonemapper
+		}
+
+	}
+
+	envFloat("VOXELRPG_POST_GAMMA", &s.Gamma)
+	envFloat("VOXELRPG_POST_VIGNETTE_STRENGTH", &s.VignetteStrength)
+	envFloat("VOXELRPG_POST_VIGNETTE_RADIUS", &s.VignetteRadius)
+
+	if value := os.Getenv("VOXELRPG_POST_LUT"); value != "" {
+		s.LUTFile = value
+	}
+
+	envFloat("VOXELRPG_POST_LUT_STRENGTH", &s.LUTStrength)
+
+}
+
+// ApplyEnv turns on the comma separated passes in VOXELRPG_POST_ENABLE and turns off the ones in
+// VOXELRPG_POST_DISABLE, such as VOXELRPG_POST_ENABLE=gamma,vignette
+func (g *RenderGraph) ApplyEnv() {
+
+	for _, setting := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"VOXELRPG_POST_ENABLE", true},
+		{"VOXELRPG_POST_DISABLE", false},
+	} {
+
+		for _, pass := range strings.Split(os.Getenv(setting.name), ",") {
+			if pass = strings.TrimSpace(pass); pass != "" {
+				g.SetEnabled(pass, setting.enabled)
+			}
+		}
+
+	}
+
+}
+
+func newPostTarget(width, height int32) postTarget {
+
+	var target postTarget
+
+	target.color = newRenderTexture(gl.RGBA16F, gl.RGBA, gl.LINEAR, width, height)
+
+	gl.GenFramebuffers(1, &target.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, target.fbo)
+	GPU.Label(gl.FRAMEBUFFER, target.fbo, "Post Processing FBO")
+
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, target.color, 0)
+
+	if err := checkFramebuffer("post processing"); err != nil {
+		Log.GL.Error("Post processing target unavailable", "error", err)
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	return target
+
+}
+
+func (target *postTarget) delete() {
+
+	gl.DeleteFramebuffers(1, &target.fbo)
+	gl.DeleteTextures(1, &target.color)
+
+}
+
+// Resize reallocates the intermediate targets at the window resolution
+func (g *RenderGraph) Resize(width, height int32) {
+
+	if width == g.width && height == g.height {
+		return
+	}
+
+	for i := range g.targets {
+
+		if g.targets[i].fbo != 0 {
+			g.targets[i].delete()
+		}
+
+		g.targets[i] = newPostTarget(width, height)
+
+	}
+
+	g.width = width
+	g.height = height
+
+}
+
+// Delete frees the ping-pong targets, the next Resize creates them again
+func (g *RenderGraph) Delete() {
+
+	for i := range g.targets {
+
+		if g.targets[i].fbo != 0 {
+			g.targets[i].delete()
+		}
+
+		g.targets[i] = postTarget{}
+
+	}
+
+	g.width, g.height = 0, 0
+
+}
+
+func (g *RenderGraph) Pass(name string) *PostPass {
+
+	for _, pass := range g.Passes {
+		if pass.Name == name {
+			return pass
+		}
+	}
+
+	return nil
+
+}
+
+// SetEnabled toggles a pass by name, returning false if there is no such pass
+func (g *RenderGraph) SetEnabled(name string, enabled bool) bool {
+
+	pass := g.Pass(name)
+
+	if pass == nil {
+		Log.GL.Warn("Unknown post processing pass", "pass", name)
+		return false
+	}
+
+	pass.Enabled = enabled
+
+	return true
+
+}
+
+// Execute runs the enabled passes over the input texture, drawing the result to the screen
+func (g *RenderGraph) Execute(input uint32) {
+	g.execute(input, false)
+}
+
+func (g *RenderGraph) execute(input uint32, perPixelOnly bool) {
+
+	var passes []*PostPass
+
+	for _, pass := range g.Passes {
+		if pass.Enabled && *pass.program != nil && (pass.PerPixel || !perPixelOnly) {
+			passes = append(passes, pass)
+		}
+	}
+
+	// Always draw something, even with every pass turned off
+
+	if len(passes) == 0 {
+		passes = append(passes, g.Passes[0])
+	}
+
+	gl.BindVertexArray(screenVAO)
+
+	for i, pass := range passes {
+
+		target := g.targets[i%2]
+
+		if i == len(passes)-1 {
+			target = postTarget{}
+		}
+
+		gl.BindFramebuffer(gl.FRAMEBUFFER, target.fbo)
+		gl.Viewport(0, 0, g.width, g.height)
+
+		program := *pass.program
+		program.Use()
+
+		gl.ActiveTexture(gl.TEXTURE0)
+		gl.BindTexture(gl.TEXTURE_2D, input)
+		program.SetInt("tex", 0)
+
+		if pass.setup != nil {
+			pass.setup(program)
+		}
+
+		gl.DrawArrays(gl.TRIANGLES, 0, 6)
+
+		input = target.color
+
+	}
+
+	gl.ActiveTexture(gl.TEXTURE0)
+
+}
+
+func newLUTTexture(size int32, data []uint8) uint32 {
+
+	var texture uint32
+
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_3D, texture)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.TexImage3D(gl.TEXTURE_3D, 0, gl.RGBA8, size, size, size, 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(data))
+	gl.BindTexture(gl.TEXTURE_3D, 0)
+
+	return texture
+
+}
+
+// identityLUT maps every colour to itself, so the grading pass does nothing until a LUT is loaded
+func identityLUT(size int) []uint8 {
+
+	data := make([]uint8, 0, size*size*size*4)
+
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				scale := func(v int) uint8 { return uint8(v * 255 / (size - 1)) }
+				data = append(data, scale(r), scale(g), scale(b), 255)
+			}
+		}
+	}
+
+	return data
+
+}
+
+// LoadColorLUT reads a strip of blue slices, red along x and green along y within each slice
+func LoadColorLUT(file string) (uint32, error) {
+
+	imgFile, err := os.Open(file)
+
+	if err != nil {
+		return 0, fmt.Errorf("colour LUT %q not found on disk: %v", file, err)
+	}
+
+	defer imgFile.Close()
+
+	img, _, err := image.Decode(imgFile)
+
+	if err != nil {
+		return 0, fmt.Errorf("colour LUT %q: %v", file, err)
+	}
+
+	bounds := img.Bounds()
+	size := bounds.Dy()
+
+	if size < 2 || bounds.Dx() != size*size {
+		return 0, fmt.Errorf("colour LUT %q is %dx%d, expected %dx%d", file, bounds.Dx(), size, size*size, size)
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), size))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+	// Reorder the slices side by side into consecutive depth layers
+
+	data := make([]uint8, 0, size*size*size*4)
+
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			start := rgba.PixOffset(b*size, g)
+			data = append(data, rgba.Pix[start:start+size*4]...)
+		}
+	}
+
+	return newLUTTexture(int32(size), data), nil
+
+}
+
+func setupPostProcessing() {
+
+	if PostConfig.LUTFile != "" {
+
+		texture, err := LoadColorLUT(PostConfig.LUTFile)
+
+		if err == nil {
+			colorLUT = texture
+			PostProcessing.SetEnabled("lut", true)
+			return
+		}
+
+		Log.GL.Error("Colour grading LUT not loaded, using an identity LUT", "error", err)
+
+	}
+
+	colorLUT = newLUTTexture(16, identityLUT(16))
+
+}
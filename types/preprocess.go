@@ -0,0 +1,222 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	Shaders "VoxelRPG/shaders"
+	World "VoxelRPG/world"
+)
+
+/* -- [[ GLSL Preprocessor ]] -- */
+
+// Shader sources may `#include "file.glsl"` other files relative to the shaders directory,
+// every file is only included once. Go constants are injected as #defines below #version.
+
+type ShaderDefine struct {
+	Name  string
+	Value string
+}
+
+type SourceLine struct {
+	File string
+	Line int
+}
+
+type ShaderSource struct {
+	Name   string
+	Source string
+
+	Lines []SourceLine // Original file and line of every line in Source
+	Files []string     // Every file read, the shader itself first
+}
+
+var shaderInclude = regexp.MustCompile(`^\s*#include\s+"([^"]+)"\s*$`)
+
+func ShaderDefines() []ShaderDefine {
+
+	return []ShaderDefine{
+		{"CHUNK_SIZE", fmt.Sprint(World.CHUNK_SIZE)},
+		{"GRID_SIZES", fmt.Sprint(World.GRID_SIZES)},
+		{"MAX_STEPS", fmt.Sprint(World.MAX_STEPS)},
+
+		{"FLAG_OCCUPIED", fmt.Sprintf("%du", World.FlagOccupied)},
+		{"FLAG_LEAF", fmt.Sprintf("%du", World.FlagLeaf)},
+		{"MAX_UINT32", fmt.Sprintf("0x%Xu", World.MaxUINT32)},
+
+		{"MATERIAL_AIR", fmt.Sprintf("%du", World.MaterialAir)},
+		{"MATERIAL_FLAG_TRANSPARENT", fmt.Sprintf("%du", World.MaterialFlagTransparent)},
+
+		{"LIGHT_POINT", fmt.Sprintf("%du", World.LightPoint)},
+		{"LIGHT_SPOT", fmt.Sprintf("%du", World.LightSpot)},
+
+		{"AO_OFF", fmt.Sprint(World.AOOff)},
+		{"AO_LOW", fmt.Sprint(World.AOLow)},
+		{"AO_HIGH", fmt.Sprint(World.AOHigh)},
+
+		{"LIGHTING_RAY_TRACED", fmt.Sprint(LightingRayTraced)},
+		{"LIGHTING_FLOOD_FILL", fmt.Sprint(LightingFloodFill)},
+
+		{"UPSCALE_LINEAR", fmt.Sprint(UpscaleLinear)},
+		{"UPSCALE_NEAREST", fmt.Sprint(UpscaleNearest)},
+
+		{"TONEMAP_NONE", fmt.Sprint(int32(TonemapNone))},
+		{"TONEMAP_REINHARD", fmt.Sprint(int32(TonemapReinhard))},
+		{"TONEMAP_ACES", fmt.Sprint(int32(TonemapACES))},
+
+		{"DEBUG_VIEW_OFF", fmt.Sprint(int32(World.DebugViewOff))},
+		{"DEBUG_VIEW_STEPS", fmt.Sprint(int32(World.DebugViewSteps))},
+		{"DEBUG_VIEW_DEPTH", fmt.Sprint(int32(World.DebugViewDepth))},
+		{"DEBUG_VIEW_CHUNKS", fmt.Sprint(int32(World.DebugViewChunks))},
+		{"DEBUG_VIEW_NORMALS", fmt.Sprint(int32(World.DebugViewNormals))},
+		{"DEBUG_VIEW_DISTANCE", fmt.Sprint(int32(World.DebugViewDistance))},
+		{"DEBUG_VIEW_LOD", fmt.Sprint(int32(World.DebugViewLOD))},
+		{"DEBUG_STEP_SCALE", fmt.Sprint(World.DebugStepScale)},
+	}
+
+}
+
+// ShaderOverrideDir is searched before the embedded shaders, set it to edit shaders without rebuilding
+var ShaderOverrideDir = os.Getenv("VOXELRPG_SHADER_DIR")
+
+// shaderFiles holds the shaders built into the binary, tests swap in their own
+var shaderFiles fs.FS = Shaders.Files
+
+func shaderSearchPaths(name string) []string {
+
+	var paths []string
+
+	if ShaderOverrideDir != "" {
+		paths = append(paths, filepath.Join(ShaderOverrideDir, filepath.FromSlash(name)))
+	}
+
+	return append(paths, "embedded:"+name)
+
+}
+
+func readShaderFile(name string) (string, error) {
+
+	if ShaderOverrideDir != "" {
+
+		data, err := os.ReadFile(filepath.Join(ShaderOverrideDir, filepath.FromSlash(name)))
+
+		if err == nil {
+			return string(data), nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+	}
+
+	data, err := fs.ReadFile(shaderFiles, name)
+
+	if err != nil {
+		return "", fmt.Errorf("shader %q not found, searched: %v", name, strings.Join(shaderSearchPaths(name), ", "))
+	}
+
+	return string(data), nil
+
+}
+
+func PreprocessShader(name string) (*ShaderSource, error) {
+
+	output := &ShaderSource{Name: name}
+
+	var source strings.Builder
+
+	included := map[string]bool{}
+
+	emit := func(line string, origin SourceLine) {
+		source.WriteString(line + "\n")
+		output.Lines = append(output.Lines, origin)
+	}
+
+	var expand func(file string, stack []string) error
+
+	expand = func(file string, stack []string) error {
+
+		for _, parent := range stack {
+			if parent == file {
+				return fmt.Errorf("include cycle: %v -> %v", strings.Join(stack, " -> "), file)
+			}
+		}
+
+		if included[file] {
+			return nil
+		}
+
+		included[file] = true
+		output.Files = append(output.Files, file)
+
+		data, err := readShaderFile(file)
+
+		if err != nil {
+
+			if len(stack) > 0 {
+				return fmt.Errorf("%v: %w", stack[len(stack)-1], err)
+			}
+
+			return err
+
+		}
+
+		for i, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+
+			origin := SourceLine{File: file, Line: i + 1}
+
+			if match := shaderInclude.FindStringSubmatch(line); match != nil {
+
+				if err := expand(path.Join(path.Dir(file), match[1]), append(stack, file)); err != nil {
+					return err
+				}
+
+				continue
+
+			}
+
+			emit(line, origin)
+
+			// Defines go straight after #version, which has to stay the first line
+
+			if len(stack) == 0 && strings.HasPrefix(strings.TrimSpace(line), "#version") {
+
+				for _, define := range ShaderDefines() {
+					emit("#define "+define.Name+" "+define.Value, SourceLine{File: "<defines>", Line: 0})
+				}
+
+			}
+
+		}
+
+		return nil
+
+	}
+
+	if err := expand(name, nil); err != nil {
+		return nil, err
+	}
+
+	output.Source = source.String()
+
+	return output, nil
+
+}
+
+// Origin returns where a line of the preprocessed source came from, lines start at 1
+func (s *ShaderSource) Origin(line int) (SourceLine, bool) {
+
+	if line < 1 || line > len(s.Lines) {
+		return SourceLine{}, false
+	}
+
+	return s.Lines[line-1], true
+
+}
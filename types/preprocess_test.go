@@ -0,0 +1,188 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// useShaderFiles preprocesses shaders from the given files instead of the embedded ones
+func useShaderFiles(t *testing.T, files map[string]string) {
+
+	embedded, overrideDir := shaderFiles, ShaderOverrideDir
+
+	t.Cleanup(func() {
+		shaderFiles, ShaderOverrideDir = embedded, overrideDir
+	})
+
+	memory := fstest.MapFS{}
+
+	for name, data := range files {
+		memory[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+
+	shaderFiles, ShaderOverrideDir = memory, ""
+
+}
+
+// sourceLines lists the preprocessed lines as "file:line text", leaving out the injected defines
+func sourceLines(source *ShaderSource) []string {
+
+	var lines []string
+
+	for i, text := range strings.Split(strings.TrimSuffix(source.Source, "\n"), "\n") {
+
+		origin := source.Lines[i]
+
+		if origin.File != "<defines>" {
+			lines = append(lines, fmt.Sprintf("%v:%d %v", origin.File, origin.Line, text))
+		}
+
+	}
+
+	return lines
+
+}
+
+func TestPreprocessShader(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+		err   string
+	}{
+		{
+			name: "nested includes",
+			files: map[string]string{
+				"main.frag":  "#version 460\n#include \"lib/a.glsl\"\nvoid main() {}",
+				"lib/a.glsl": "#include \"b.glsl\"\nfloat a;",
+				"lib/b.glsl": "float b;",
+			},
+			want: []string{"main.frag:1 #version 460", "lib/b.glsl:1 float b;", "lib/a.glsl:2 float a;", "main.frag:3 void main() {}"},
+		},
+		{
+			name: "included once",
+			files: map[string]string{
+				"main.frag":   "#version 460\n#include \"a.glsl\"\n#include \"b.glsl\"\n#include \"common.glsl\"",
+				"a.glsl":      "#include \"common.glsl\"\nfloat a;",
+				"b.glsl":      "#include \"common.glsl\"\nfloat b;",
+				"common.glsl": "float common;",
+			},
+			want: []string{"main.frag:1 #version 460", "common.glsl:1 float common;", "a.glsl:2 float a;", "b.glsl:2 float b;"},
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"main.frag": "#version 460\n#include \"a.glsl\"",
+				"a.glsl":    "#include \"b.glsl\"",
+				"b.glsl":    "#include \"a.glsl\"",
+			},
+			err: "include cycle: main.frag -> a.glsl -> b.glsl -> a.glsl",
+		},
+		{
+			name: "missing include",
+			files: map[string]string{
+				"main.frag": "#version 460\n#include \"a.glsl\"",
+				"a.glsl":    "#include \"missing.glsl\"",
+			},
+			err: `a.glsl: shader "missing.glsl" not found`,
+		},
+	}
+
+	for _, test := range tests {
+
+		useShaderFiles(t, test.files)
+
+		source, err := PreprocessShader("main.frag")
+
+		if test.err != "" {
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%v: error %v, want one containing %q", test.name, err, test.err)
+			}
+
+			continue
+
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		if got := sourceLines(source); strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%v: preprocessed to\n%v\nwant\n%v", test.name, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+
+	}
+
+}
+
+func TestShaderDefinesFollowVersion(t *testing.T) {
+
+	useShaderFiles(t, map[string]string{
+		"main.frag": "// A comment may come before #version\n#version 460\n#include \"a.glsl\"",
+		"a.glsl":    "float a;",
+	})
+
+	source, err := PreprocessShader("main.frag")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defines := ShaderDefines()
+	lines := strings.Split(strings.TrimSuffix(source.Source, "\n"), "\n")
+
+	if len(lines) != len(defines)+3 || len(source.Lines) != len(lines) {
+		t.Fatalf("preprocessed %d lines with %d origins, want %d", len(lines), len(source.Lines), len(defines)+3)
+	}
+
+	if lines[1] != "#version 460" {
+		t.Errorf("second line is %q, want #version", lines[1])
+	}
+
+	for i, define := range defines {
+
+		line, origin := lines[i+2], source.Lines[i+2]
+
+		if line != "#define "+define.Name+" "+define.Value || origin.File != "<defines>" {
+			t.Errorf("line %d is %q from %v, want the %v define", i+3, line, origin.File, define.Name)
+		}
+
+	}
+
+	if last := lines[len(lines)-1]; last != "float a;" {
+		t.Errorf("last line is %q, want the include", last)
+	}
+
+}
+
+func TestAnnotateShaderLog(t *testing.T) {
+
+	useShaderFiles(t, map[string]string{
+		"main.frag":  "#version 460\n#include \"lib/a.glsl\"\nvoid main() {}",
+		"lib/a.glsl": "float a;",
+	})
+
+	source, err := PreprocessShader("main.frag")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The include lands right after #version and the defines
+
+	line := len(ShaderDefines()) + 2
+
+	log := fmt.Sprintf("0:%d(5): error: mesa\nno line here\n0(%d) : error: nvidia\n0:9999(1): error: past the end\x00", line, line)
+
+	want := fmt.Sprintf("0:%d(5): error: mesa\n    lib/a.glsl:1 | float a;\nno line here\n0(%d) : error: nvidia\n    lib/a.glsl:1 | float a;\n0:9999(1): error: past the end\n", line, line)
+
+	if got := annotateShaderLog(source, log); got != want {
+		t.Errorf("annotated log\n%v\nwant\n%v", got, want)
+	}
+
+}
@@ -1,23 +1,81 @@
 package types
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"sort"
+)
 
-type Profiler struct {
-	StartTime   time.Time
-	EndProfiler func(Profiler) time.Duration
+/* -- [[ Rolling Timing Statistics ]] -- */
+
+const TimingWindow = 240 // Samples kept per statistic, a few seconds of frames
+
+type TimingSummary struct {
+	Count int `json:"count"`
+
+	Last float64 `json:"lastMs"`
+	Min  float64 `json:"minMs"`
+	Avg  float64 `json:"avgMs"`
+	Max  float64 `json:"maxMs"`
+	P99  float64 `json:"p99Ms"`
 }
 
-func __profilerEnd(profile Profiler) time.Duration {
+// TimingStats keeps the last TimingWindow samples of a duration in milliseconds
+type TimingStats struct {
+	samples [TimingWindow]float64
+	next    int
+	count   int
+}
+
+func (s *TimingStats) Add(ms float64) {
 
-	return time.Since(profile.StartTime)
+	s.samples[s.next] = ms
+	s.next = (s.next + 1) % TimingWindow
+	s.count = min(s.count+1, TimingWindow)
 
 }
 
-func ProfilerStart(id string) Profiler {
+func (s *TimingStats) Summary() TimingSummary {
 
-	return Profiler{
-		StartTime:   time.Now(),
-		EndProfiler: __profilerEnd,
+	if s.count == 0 {
+		return TimingSummary{}
 	}
 
+	summary := summarizeTimings(s.samples[:s.count])
+	summary.Last = s.samples[(s.next+TimingWindow-1)%TimingWindow]
+
+	return summary
+
+}
+
+// summarizeTimings is the count, min, average, max and 99th percentile of some samples, in any order
+func summarizeTimings(samples []float64) TimingSummary {
+
+	if len(samples) == 0 {
+		return TimingSummary{}
+	}
+
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+
+	total := 0.0
+
+	for _, sample := range sorted {
+		total += sample
+	}
+
+	return TimingSummary{
+		Count: len(sorted),
+
+		Min: sorted[0],
+		Avg: total / float64(len(sorted)),
+		Max: sorted[len(sorted)-1],
+		P99: sorted[int(math.Ceil(0.99*float64(len(sorted))))-1],
+	}
+
+}
+
+func (s TimingSummary) String() string {
+	return fmt.Sprintf("avg %.2f ms, min %.2f, max %.2f, p99 %.2f", s.Avg, s.Min, s.Max, s.P99)
 }
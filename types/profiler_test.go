@@ -0,0 +1,89 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestSummarizeTimings(t *testing.T) {
+
+	hundred := make([]float64, 100)
+
+	for i := range hundred {
+		hundred[i] = float64(100 - i)
+	}
+
+	tests := []struct {
+		name    string
+		samples []float64
+		want    TimingSummary
+	}{
+		{"none", nil, TimingSummary{}},
+		{"one", []float64{4}, TimingSummary{Count: 1, Min: 4, Avg: 4, Max: 4, P99: 4}},
+		{"unordered", []float64{3, 1, 4, 2}, TimingSummary{Count: 4, Min: 1, Avg: 2.5, Max: 4, P99: 4}},
+		{"a hundred, p99 below the max", hundred, TimingSummary{Count: 100, Min: 1, Avg: 50.5, Max: 100, P99: 99}},
+	}
+
+	for _, test := range tests {
+		if got := summarizeTimings(test.samples); got != test.want {
+			t.Errorf("%v: summary %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+}
+
+func TestTimingStatsRollingWindow(t *testing.T) {
+
+	var stats TimingStats
+
+	if summary := stats.Summary(); summary != (TimingSummary{}) {
+		t.Errorf("empty stats summarised to %+v", summary)
+	}
+
+	for i := 0; i < TimingWindow; i++ {
+		stats.Add(10)
+	}
+
+	for i := 0; i < 5; i++ {
+		stats.Add(1)
+	}
+
+	stats.Add(40)
+
+	summary := stats.Summary()
+	avg := (float64(TimingWindow-6)*10 + 5 + 40) / TimingWindow
+
+	if summary.Count != TimingWindow || summary.Last != 40 || summary.Min != 1 || summary.Max != 40 || !closeTo(summary.Avg, avg) {
+		t.Errorf("after wrapping the window, summary %+v, want %d samples, last 40, min 1, max 40 and avg %v", summary, TimingWindow, avg)
+	}
+
+	// A full window later the old samples have rolled out
+
+	for i := 0; i < TimingWindow; i++ {
+		stats.Add(2)
+	}
+
+	if summary := stats.Summary(); summary.Min != 2 || summary.Max != 2 || summary.Avg != 2 || summary.Last != 2 {
+		t.Errorf("after a full window of 2 ms, summary %+v", summary)
+	}
+
+}
+
+func TestQueryMilliseconds(t *testing.T) {
+
+	if got := queryMilliseconds(1_000_000_000, 1_001_500_000); got != 1.5 {
+		t.Errorf("1.5 million ns between queries is %v ms, want 1.5", got)
+	}
+
+	// Unmeasured scopes report nothing
+
+	timers := &GPUTimers{timers: map[string]*GPUTimer{"raymarch": {Name: "raymarch"}}, order: []string{"raymarch"}}
+
+	if _, ok := timers.Stats("raymarch"); ok {
+		t.Error("a scope without results has stats")
+	}
+
+	if summaries := timers.Summaries(); len(summaries) != 0 {
+		t.Errorf("summaries %v, want none", summaries)
+	}
+
+}
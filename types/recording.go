@@ -0,0 +1,396 @@
+package types
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	Client "VoxelRPG/client"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+/* -- [[ Frame Recording ]] -- */
+
+// While recording, every rendered frame advances the scene clock by exactly 1 / FrameRate no
+// matter how long it took, and is written to disk as a numbered PNG or into a Y4M stream. With
+// a camera path the camera follows it and recording stops at its end, so a run can be repeated.
+
+type RecordFormat int
+
+const (
+	RecordPNG RecordFormat = iota
+	RecordY4M
+)
+
+var recordFormatNames = [...]string{"png", "y4m"}
+
+func (f RecordFormat) String() string {
+	return recordFormatNames[f]
+}
+
+type recordedFrame struct {
+	index int
+	image *image.RGBA
+}
+
+type FrameRecorder struct {
+	FrameRate int
+	Format    RecordFormat
+	Directory string
+
+	PathFile  string  // Camera path to follow, empty to keep the player in control
+	Frames    int     // Frames to record without a path, 0 records until stopped
+	StartTime float64 // Scene time of the first frame, which sets the time of day
+
+	ToggleKey glfw.Key
+
+	mutex     sync.Mutex
+	requested bool // Wanted state, applied by the render loop at the start of a frame
+
+	active    bool
+	path      *CameraPath
+	frame     int
+	output    string
+	wallStart time.Time
+
+	frames chan recordedFrame
+	writer sync.WaitGroup
+}
+
+var Recording = &FrameRecorder{
+	FrameRate: 60,
+	Format:    RecordPNG,
+	Directory: "recordings",
+
+	ToggleKey: glfw.KeyF5,
+}
+
+/* -- [[ Settings ]] -- */
+
+func ParseRecordFormat(name string) (RecordFormat, error) {
+
+	for format, formatName := range recordFormatNames {
+		if strings.EqualFold(name, formatName) {
+			return RecordFormat(format), nil
+		}
+	}
+
+	return RecordPNG, fmt.Errorf("unknown recording format %q", name)
+
+}
+
+// ApplyEnv reads VOXELRPG_RECORD_PATH, VOXELRPG_RECORD_FORMAT and VOXELRPG_RECORD_FRAMES over the recorder's settings
+func (r *FrameRecorder) ApplyEnv() {
+
+	if value := os.Getenv("VOXELRPG_RECORD_PATH"); value != "" {
+		r.PathFile = value
+	}
+
+	if value := os.Getenv("VOXELRPG_RECORD_FORMAT"); value != "" {
+
+		if format, err := ParseRecordFormat(value); err != nil {
+			captureLog.Warn("Ignoring VOXELRPG_RECORD_FORMAT", "error", err)
+		} else {
+			r.Format = format
+		}
+
+	}
+
+	if value := os.Getenv("VOXELRPG_RECORD_FRAMES"); value != "" {
+
+		if frames, err := strconv.Atoi(value); err != nil || frames < 0 {
+			captureLog.Warn("Ignoring VOXELRPG_RECORD_FRAMES", "value", value)
+		} else {
+			r.Frames = frames
+		}
+
+	}
+
+}
+
+/* -- [[ Control ]] -- */
+
+func (r *FrameRecorder) Start() {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requested = true
+
+}
+
+func (r *FrameRecorder) Stop() {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requested = false
+
+}
+
+func (r *FrameRecorder) Toggle() {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requested = !r.requested
+
+}
+
+// Active is only meaningful on the render loop, other goroutines see the requested state lag by a frame
+func (r *FrameRecorder) Active() bool {
+	return r.active
+}
+
+// Clock starts or stops a pending recording and returns the scene time for this frame
+func (r *FrameRecorder) Clock(now float64) float64 {
+
+	r.mutex.Lock()
+	requested := r.requested
+	r.mutex.Unlock()
+
+	if requested && !r.active {
+		r.begin()
+	}
+
+	if !requested && r.active {
+		r.finish()
+	}
+
+	if !r.active {
+		return now
+	}
+
+	return r.StartTime + r.pathTime()
+
+}
+
+func (r *FrameRecorder) pathTime() float64 {
+	return float64(r.frame) / float64(r.FrameRate)
+}
+
+// ApplyCamera moves the camera along the path for the current frame
+func (r *FrameRecorder) ApplyCamera(cam *Client.Camera) {
+
+	if !r.active || r.path == nil {
+		return
+	}
+
+	cam.Pos, cam.Yaw, cam.Pitch = r.path.Sample(r.pathTime())
+	cam.Front = cameraFront(cam.Yaw, cam.Pitch)
+
+}
+
+func (r *FrameRecorder) begin() {
+
+	if r.PathFile != "" {
+
+		path, err := LoadCameraPath(r.PathFile)
+
+		if err != nil {
+
+			captureLog.Error("Recording not started", "error", err)
+
+			r.mutex.Lock()
+			r.requested = false
+			r.mutex.Unlock()
+
+			return
+
+		}
+
+		r.path = path
+
+	}
+
+	r.output = filepath.Join(r.Directory, time.Now().Format("2006-01-02_15-04-05"))
+
+	if r.Format == RecordY4M {
+		r.output += ".y4m"
+	}
+
+	r.frame = 0
+	r.active = true
+	r.wallStart = time.Now()
+
+	// Frames queue up for the writer, the render loop only waits once the queue is full
+
+	r.frames = make(chan recordedFrame, 8)
+	r.writer.Add(1)
+
+	go r.write(r.output, r.frames)
+
+	captureLog.Info("Recording", "output", r.output, "fps", r.FrameRate)
+
+}
+
+func (r *FrameRecorder) finish() {
+
+	close(r.frames)
+	r.writer.Wait()
+
+	elapsed := time.Since(r.wallStart).Seconds()
+
+	captureLog.Info("Recording finished", "frames", r.frame, "seconds", fmt.Sprintf("%.2f", elapsed), "msPerFrame", fmt.Sprintf("%.2f", elapsed*1000/float64(max(r.frame, 1))))
+
+	r.active = false
+	r.path = nil
+
+	r.mutex.Lock()
+	r.requested = false
+	r.mutex.Unlock()
+
+}
+
+// Close ends a recording in progress, for when the render loop exits
+func (r *FrameRecorder) Close() {
+
+	if r.active {
+		r.finish()
+	}
+
+}
+
+// Capture reads back the finished frame, called after post processing
+func (r *FrameRecorder) Capture(windowBuilder *WindowBuilder) {
+
+	if !r.active {
+		return
+	}
+
+	img := ReadFramebuffer(0, gl.BACK, int32(windowBuilder.Width), int32(windowBuilder.Height))
+
+	r.frames <- recordedFrame{index: r.frame, image: img}
+	r.frame++
+
+	if r.path != nil && r.pathTime() > r.path.Duration() {
+		r.finish()
+		return
+	}
+
+	if r.Frames > 0 && r.frame >= r.Frames {
+		r.finish()
+	}
+
+}
+
+func (r *FrameRecorder) write(output string, frames chan recordedFrame) {
+
+	defer r.writer.Done()
+
+	if r.Format == RecordPNG {
+
+		for frame := range frames {
+
+			file := filepath.Join(output, fmt.Sprintf("frame_%06d.png", frame.index))
+
+			if err := writePNG(file, frame.image); err != nil {
+				captureLog.Error("Recording frame failed", "frame", frame.index, "error", err)
+			}
+
+		}
+
+		return
+
+	}
+
+	err := writeY4M(output, r.FrameRate, frames)
+
+	if err != nil {
+
+		captureLog.Error("Recording failed", "error", err)
+
+		for range frames {
+			// Keep draining so the render loop is not blocked
+		}
+
+	}
+
+}
+
+func writeY4M(file string, frameRate int, frames chan recordedFrame) error {
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	output, err := os.Create(file)
+
+	if err != nil {
+		return err
+	}
+
+	defer output.Close()
+
+	writer := bufio.NewWriter(output)
+
+	var size image.Point
+
+	for frame := range frames {
+
+		// The stream header needs the size, which is only known once the first frame arrives
+
+		if frame.index == 0 {
+			size = frame.image.Rect.Size()
+			fmt.Fprintf(writer, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C444\n", size.X, size.Y, frameRate)
+		}
+
+		if frame.image.Rect.Size() != size {
+			captureLog.Warn("Recording frame skipped, the window was resized during a Y4M recording", "frame", frame.index)
+			continue
+		}
+
+		if err := writeY4MFrame(writer, frame.image); err != nil {
+			return err
+		}
+
+	}
+
+	return writer.Flush()
+
+}
+
+// writeY4MFrame converts to BT.601 limited range YCbCr without chroma subsampling
+func writeY4MFrame(writer io.Writer, img *image.RGBA) error {
+
+	size := img.Rect.Size()
+	pixels := size.X * size.Y
+
+	planes := make([]uint8, pixels*3)
+
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+
+			offset := img.PixOffset(x, y)
+
+			r := float64(img.Pix[offset])
+			g := float64(img.Pix[offset+1])
+			b := float64(img.Pix[offset+2])
+
+			i := y*size.X + x
+
+			planes[i] = uint8(16 + (65.738*r+129.057*g+25.064*b)/256)
+			planes[pixels+i] = uint8(128 + (-37.945*r-74.494*g+112.439*b)/256)
+			planes[2*pixels+i] = uint8(128 + (112.439*r-94.154*g-18.285*b)/256)
+
+		}
+	}
+
+	if _, err := io.WriteString(writer, "FRAME\n"); err != nil {
+		return err
+	}
+
+	_, err := writer.Write(planes)
+
+	return err
+
+}
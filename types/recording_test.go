@@ -0,0 +1,69 @@
+package types
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestWriteY4MFrame(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		color color.RGBA
+		yuv   [3]uint8
+	}{
+		{"black", color.RGBA{0, 0, 0, 255}, [3]uint8{16, 128, 128}},
+		{"white", color.RGBA{255, 255, 255, 255}, [3]uint8{235, 128, 128}},
+		{"red", color.RGBA{255, 0, 0, 255}, [3]uint8{81, 90, 239}},
+		{"blue", color.RGBA{0, 0, 255, 255}, [3]uint8{40, 239, 109}},
+	}
+
+	for _, test := range tests {
+
+		// The colour sits next to a black pixel, so a plane written in the wrong place shows up
+
+		img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+		img.SetRGBA(0, 0, test.color)
+		img.SetRGBA(1, 0, color.RGBA{0, 0, 0, 255})
+
+		var output bytes.Buffer
+
+		if err := writeY4MFrame(&output, img); err != nil {
+			t.Fatal(err)
+		}
+
+		want := append([]byte("FRAME\n"), test.yuv[0], 16, test.yuv[1], 128, test.yuv[2], 128)
+
+		if !bytes.Equal(output.Bytes(), want) {
+			t.Errorf("%v: wrote %v, want %v", test.name, output.Bytes(), want)
+		}
+
+	}
+
+}
+
+func TestParseRecordFormat(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		format RecordFormat
+		valid  bool
+	}{
+		{"png", RecordPNG, true},
+		{"Y4M", RecordY4M, true},
+		{"mp4", RecordPNG, false},
+	}
+
+	for _, test := range tests {
+
+		format, err := ParseRecordFormat(test.name)
+
+		if format != test.format || (err == nil) != test.valid {
+			t.Errorf("ParseRecordFormat(%q) = %v, %v", test.name, format, err)
+		}
+
+	}
+
+}
@@ -0,0 +1,133 @@
+package types
+
+import (
+	Log "VoxelRPG/logging"
+)
+
+/* -- [[ Dynamic Resolution ]] -- */
+
+// The raymarcher renders into the FBO at Scale times the window size, which is then upscaled
+// to the screen. The controller lowers the scale while frames are slower than the target.
+
+type UpscaleFilter int32
+
+const (
+	UpscaleLinear UpscaleFilter = iota
+	UpscaleNearest
+)
+
+type ResolutionScaler struct {
+	Enabled bool
+
+	TargetFPS float64
+
+	MinScale float32
+	MaxScale float32
+	Step     float32 // Scale change per adjustment
+
+	Hysteresis     float64 // Fraction around the target frame time where the scale is left alone
+	AdjustInterval float64 // Seconds of frames averaged before each adjustment
+
+	Filter UpscaleFilter
+
+	Scale float32
+
+	lastFrame   float64
+	windowStart float64
+	frameTime   float64
+	frames      int
+}
+
+var DynamicResolution = &ResolutionScaler{
+	Enabled: true,
+
+	TargetFPS: 60,
+
+	MinScale: 0.35,
+	MaxScale: 1,
+	Step:     0.05,
+
+	Hysteresis:     0.15,
+	AdjustInterval: 0.5,
+
+	Filter: UpscaleLinear,
+
+	Scale: 1,
+}
+
+// Frame records the time of a new frame and resizes the FBO when the scale changes
+func (r *ResolutionScaler) Frame(now float64, windowBuilder *WindowBuilder) {
+
+	average, ok := r.sample(now)
+
+	if !ok {
+		return
+	}
+
+	r.SetScale(r.nextScale(average), windowBuilder)
+
+}
+
+// sample adds a frame, returning the average frame time once AdjustInterval has passed
+func (r *ResolutionScaler) sample(now float64) (float64, bool) {
+
+	if r.lastFrame == 0 {
+		r.lastFrame = now
+		r.windowStart = now
+		return 0, false
+	}
+
+	r.frameTime += now - r.lastFrame
+	r.frames++
+	r.lastFrame = now
+
+	if !r.Enabled || now-r.windowStart < r.AdjustInterval {
+		return 0, false
+	}
+
+	average := r.frameTime / float64(r.frames)
+
+	r.windowStart = now
+	r.frameTime = 0
+	r.frames = 0
+
+	return average, true
+
+}
+
+// nextScale steps the scale towards the target frame time, leaving it alone inside the hysteresis
+func (r *ResolutionScaler) nextScale(average float64) float32 {
+
+	target := 1 / r.TargetFPS
+	scale := r.Scale
+
+	if average > target*(1+r.Hysteresis) {
+		scale -= r.Step
+	} else if average < target*(1-r.Hysteresis) {
+		scale += r.Step
+	}
+
+	return r.clampScale(scale)
+
+}
+
+func (r *ResolutionScaler) clampScale(scale float32) float32 {
+	return min(max(scale, r.MinScale), r.MaxScale)
+}
+
+func (r *ResolutionScaler) SetScale(scale float32, windowBuilder *WindowBuilder) {
+
+	scale = r.clampScale(scale)
+
+	if scale == r.Scale {
+		return
+	}
+
+	Log.GL.Debug("Render scale changed", "from", r.Scale, "to", scale)
+
+	r.Scale = scale
+	Scaledown = 1 / scale
+
+	ResizeFramebuffer(fbo, &fboTexture, windowBuilder.Width, windowBuilder.Height)
+
+}
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"testing"
+)
+
+// newTestScaler is the default controller, at 50 fps so frame times are round numbers
+func newTestScaler(scale float32) *ResolutionScaler {
+
+	r := *DynamicResolution
+
+	r.TargetFPS = 50
+	r.Scale = scale
+
+	return &r
+
+}
+
+func TestResolutionScaleHysteresis(t *testing.T) {
+
+	r := newTestScaler(0.5)
+	target := 1 / r.TargetFPS
+
+	tests := []struct {
+		name    string
+		average float64
+		want    float32
+	}{
+		{"on target", target, 0.5},
+		{"slow, inside the hysteresis", target * 1.1, 0.5},
+		{"fast, inside the hysteresis", target * 0.9, 0.5},
+		{"too slow", target * 1.2, 0.5 - r.Step},
+		{"too fast", target * 0.8, 0.5 + r.Step},
+	}
+
+	for _, test := range tests {
+		if got := r.nextScale(test.average); got != test.want {
+			t.Errorf("%v: %v s frames scaled 0.5 to %v, want %v", test.name, test.average, got, test.want)
+		}
+	}
+
+}
+
+func TestResolutionScaleClamping(t *testing.T) {
+
+	slow, fast := 1.0, 0.001
+
+	tests := []struct {
+		name    string
+		scale   float32
+		average float64
+		want    float32
+	}{
+		{"slow at the minimum", DynamicResolution.MinScale, slow, DynamicResolution.MinScale},
+		{"fast at the maximum", DynamicResolution.MaxScale, fast, DynamicResolution.MaxScale},
+		{"slow just above the minimum", DynamicResolution.MinScale + 0.01, slow, DynamicResolution.MinScale},
+		{"fast just below the maximum", DynamicResolution.MaxScale - 0.01, fast, DynamicResolution.MaxScale},
+	}
+
+	for _, test := range tests {
+		if got := newTestScaler(test.scale).nextScale(test.average); got != test.want {
+			t.Errorf("%v: scaled %v to %v, want %v", test.name, test.scale, got, test.want)
+		}
+	}
+
+	r := newTestScaler(1)
+
+	if got := r.clampScale(0); got != r.MinScale {
+		t.Errorf("a scale of 0 clamped to %v, want %v", got, r.MinScale)
+	}
+
+	if got := r.clampScale(2); got != r.MaxScale {
+		t.Errorf("a scale of 2 clamped to %v, want %v", got, r.MaxScale)
+	}
+
+}
+
+func TestResolutionSampling(t *testing.T) {
+
+	r := newTestScaler(1)
+	r.AdjustInterval = 0.5
+
+	// The first frame only starts the window, then every 0.5 s the average comes out
+
+	frames := []struct {
+		now     float64
+		ok      bool
+		average float64
+	}{
+		{10.0, false, 0},
+		{10.1, false, 0},
+		{10.2, false, 0},
+		{10.5, true, 0.5 / 3},
+		{10.6, false, 0},
+		{11.0, true, 0.25},
+	}
+
+	for _, frame := range frames {
+
+		average, ok := r.sample(frame.now)
+
+		if ok != frame.ok || (ok && !closeTo(average, frame.average)) {
+			t.Errorf("frame at %v: average %v, %v, want %v, %v", frame.now, average, ok, frame.average, frame.ok)
+		}
+
+	}
+
+	// A disabled controller keeps counting frames but never adjusts
+
+	r = newTestScaler(1)
+	r.Enabled = false
+
+	for now := 1.0; now < 3; now += 0.25 {
+		if _, ok := r.sample(now); ok {
+			t.Fatalf("disabled controller adjusted at %v", now)
+		}
+	}
+
+}
+
+func closeTo(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
@@ -0,0 +1,269 @@
+package types
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	Client "VoxelRPG/client"
+	Log "VoxelRPG/logging"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/* -- [[ Screenshots ]] -- */
+
+// Requests can come from any goroutine, they are carried out by the render loop at the end of the
+// next frame. Encoding the PNG happens in the background so the frame is not held up.
+
+// Screenshots and recordings log under their own subsystem
+var captureLog = Log.New("capture")
+
+type CaptureSource int
+
+const (
+	CaptureFinal    CaptureSource = iota // What is on screen after post processing
+	CaptureInternal                      // The raymarcher FBO at the internal render resolution
+)
+
+type ScreenshotCapturer struct {
+	Directory string
+
+	TileScale int // Tiled screenshots are this many times the window size along each axis
+
+	FinalKey    glfw.Key
+	InternalKey glfw.Key
+	TiledKey    glfw.Key
+
+	mutex   sync.Mutex
+	pending []CaptureSource
+	tiled   []int
+
+	saving sync.WaitGroup
+}
+
+var Screenshots = &ScreenshotCapturer{
+	Directory: "screenshots",
+
+	TileScale: 4,
+
+	FinalKey:    glfw.KeyF2,
+	InternalKey: glfw.KeyF3,
+	TiledKey:    glfw.KeyF4,
+}
+
+func SetupCaptureKeybinds(client *Client.ClientContext) {
+
+	bind := func(key glfw.Key, request func()) {
+		Client.CBOnKeyChange(client, key, func(action glfw.Action) {
+			if action == glfw.Press {
+				request()
+			}
+		})
+	}
+
+	bind(Screenshots.FinalKey, func() { Screenshots.Request(CaptureFinal) })
+	bind(Screenshots.InternalKey, func() { Screenshots.Request(CaptureInternal) })
+	bind(Screenshots.TiledKey, func() { Screenshots.RequestTiled(Screenshots.TileScale) })
+	bind(Recording.ToggleKey, Recording.Toggle)
+
+}
+
+func (s *ScreenshotCapturer) Request(source CaptureSource) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pending = append(s.pending, source)
+
+}
+
+// RequestTiled renders the next frame scale times larger than the window, one window sized tile at a time
+func (s *ScreenshotCapturer) RequestTiled(scale int) {
+
+	if scale < 1 {
+		captureLog.Warn("Tiled screenshot scale must be at least 1", "scale", scale)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.tiled = append(s.tiled, scale)
+
+}
+
+// Wait blocks until every screenshot taken so far is written
+func (s *ScreenshotCapturer) Wait() {
+	s.saving.Wait()
+}
+
+// ReadFramebuffer copies a colour buffer of a framebuffer into an image, top row first
+func ReadFramebuffer(framebuffer uint32, buffer uint32, width, height int32) *image.RGBA {
+
+	pixels := make([]uint8, width*height*4)
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, framebuffer)
+	gl.ReadBuffer(buffer)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(0, 0, width, height, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, 0)
+
+	// OpenGL rows start at the bottom, images start at the top
+
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	stride := int(width) * 4
+
+	for y := 0; y < int(height); y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+stride], pixels[(int(height)-1-y)*stride:])
+	}
+
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+
+	return img
+
+}
+
+func (s *ScreenshotCapturer) save(img *image.RGBA, suffix string) {
+
+	name := time.Now().Format("2006-01-02_15-04-05.000") + suffix + ".png"
+	file := filepath.Join(s.Directory, name)
+
+	s.saving.Add(1)
+
+	go func() {
+
+		defer s.saving.Done()
+
+		if err := writePNG(file, img); err != nil {
+			captureLog.Error("Screenshot failed", "file", file, "error", err)
+			return
+		}
+
+		captureLog.Info("Screenshot saved", "file", file, "width", img.Rect.Dx(), "height", img.Rect.Dy())
+
+	}()
+
+}
+
+func writePNG(file string, img image.Image) error {
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	output, err := os.Create(file)
+
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(output, img); err != nil {
+		output.Close()
+		return err
+	}
+
+	return output.Close()
+
+}
+
+// capture reads back the screenshots requested since the last frame, after post processing has run
+func (s *ScreenshotCapturer) capture(windowBuilder *WindowBuilder) {
+
+	s.mutex.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mutex.Unlock()
+
+	for _, source := range pending {
+
+		switch source {
+
+		case CaptureFinal:
+			s.save(ReadFramebuffer(0, gl.BACK, int32(windowBuilder.Width), int32(windowBuilder.Height)), "")
+
+		case CaptureInternal:
+			width := int32(float32(windowBuilder.Width) / Scaledown)
+			height := int32(float32(windowBuilder.Height) / Scaledown)
+
+			s.save(ReadFramebuffer(fbo, gl.COLOR_ATTACHMENT0, width, height), "_internal")
+
+		}
+
+	}
+
+}
+
+// renderTiled draws each requested tiled screenshot through the raymarcher and the per pixel post
+// passes. Tiles render at full window resolution without jitter, passes that read neighbouring
+// pixels (FXAA, vignette) would show the tile seams so they are left out.
+func (s *ScreenshotCapturer) renderTiled(cam *Client.Camera, view mgl32.Mat4, time float32, windowBuilder *WindowBuilder) {
+
+	s.mutex.Lock()
+	tiled := s.tiled
+	s.tiled = nil
+	s.mutex.Unlock()
+
+	if len(tiled) == 0 {
+		return
+	}
+
+	width := int32(windowBuilder.Width)
+	height := int32(windowBuilder.Height)
+
+	Scaledown = 1
+	ResizeFramebuffer(fbo, &fboTexture, windowBuilder.Width, windowBuilder.Height)
+
+	// Each tile draws to the screen within one frame, without the depth clear between frames
+
+	gl.Disable(gl.DEPTH_TEST)
+
+	for _, scale := range tiled {
+
+		captureLog.Info("Rendering tiled screenshot", "width", scale*int(width), "height", scale*int(height))
+
+		img := image.NewRGBA(image.Rect(0, 0, scale*int(width), scale*int(height)))
+		resolution := mgl32.Vec2{float32(scale) * float32(width), float32(scale) * float32(height)}
+
+		for tileY := 0; tileY < scale; tileY++ {
+			for tileX := 0; tileX < scale; tileX++ {
+
+				viewport := mgl32.Vec4{
+					float32(tileX) / float32(scale),
+					float32(tileY) / float32(scale),
+					1 / float32(scale),
+					1 / float32(scale),
+				}
+
+				renderScene(cam, view, mgl32.Vec2{}, time, width, height, resolution, viewport)
+				PostProcessing.execute(fboTexture, true)
+
+				// Tile rows count up from the bottom of the image
+
+				tile := ReadFramebuffer(0, gl.BACK, width, height)
+				at := image.Pt(tileX*int(width), (scale-1-tileY)*int(height))
+
+				draw.Draw(img, tile.Rect.Add(at), tile, image.Point{}, draw.Src)
+
+			}
+		}
+
+		s.save(img, fmt.Sprintf("_x%d", scale))
+
+	}
+
+	gl.Enable(gl.DEPTH_TEST)
+
+	Scaledown = 1 / DynamicResolution.Scale
+	ResizeFramebuffer(fbo, &fboTexture, windowBuilder.Width, windowBuilder.Height)
+
+}
@@ -2,36 +2,25 @@ package types
 
 import (
 	"fmt"
-	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/go-gl/gl/v4.6-core/gl"
 )
 
-func NewShaderData(shader_file_name string) (string, error) {
+func NewShader(shader_file_name string, shader_type uint32) (uint32, error) {
 
-	shader_info, err := os.ReadFile("shaders/" + shader_file_name)
+	shader_info, err := PreprocessShader(shader_file_name)
 
 	if err != nil {
-
-		return "", err
-
+		return 0, &ShaderError{File: shader_file_name, Err: err}
 	}
 
-	return string(shader_info) + "\x00", nil
-
-}
-
-func NewShader(shader_file_name string, shader_type uint32) (uint32, error) {
+	shaderSourceGoString := shader_info.Source + "\x00"
 
 	shader := gl.CreateShader(shader_type)
 
-	shaderSourceGoString, err := NewShaderData(shader_file_name)
-
-	if err != nil {
-		return 0, err
-	}
-
 	// Add source from shader file into shader object
 
 	shaderSources, free := gl.Strs(shaderSourceGoString)
@@ -55,7 +44,9 @@ func NewShader(shader_file_name string, shader_type uint32) (uint32, error) {
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
 
-		return 0, fmt.Errorf("failed to compile \n\n%v\nLog: %v", shaderSourceGoString, log)
+		gl.DeleteShader(shader)
+
+		return 0, &ShaderError{File: shader_file_name, Log: annotateShaderLog(shader_info, log)}
 
 	}
 
@@ -92,7 +83,9 @@ func NewShaderProgram(shaders []uint32) (uint32, error) {
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetProgramInfoLog(shaderProgram, logLength, nil, gl.Str(log))
 
-		return 0, fmt.Errorf("failed to link program: %v", log)
+		gl.DeleteProgram(shaderProgram)
+
+		return 0, &ShaderError{Log: strings.TrimRight(log, "\x00\n")}
 	}
 
 	// Cleanup the now unused shaders
@@ -106,3 +99,45 @@ func NewShaderProgram(shaders []uint32) (uint32, error) {
 	return shaderProgram, nil
 
 }
+
+/* -- [[ Compile Errors ]] -- */
+
+// Matches the line number of Mesa "0:12(5): error" and NVIDIA "0(12) : error" style logs
+var shaderLogLine = regexp.MustCompile(`^\d+[:(](\d+)`)
+
+// annotateShaderLog points every log line which names a line at the original file and line
+func annotateShaderLog(source *ShaderSource, log string) string {
+
+	sourceLines := strings.Split(source.Source, "\n")
+
+	var output strings.Builder
+
+	for _, line := range strings.Split(strings.TrimRight(log, "\x00\n"), "\n") {
+
+		output.WriteString(line + "\n")
+
+		match := shaderLogLine.FindStringSubmatch(line)
+
+		if match == nil {
+			continue
+		}
+
+		lineNumber, err := strconv.Atoi(match[1])
+
+		if err != nil {
+			continue
+		}
+
+		origin, ok := source.Origin(lineNumber)
+
+		if !ok {
+			continue
+		}
+
+		output.WriteString(fmt.Sprintf("    %v:%d | %v\n", origin.File, origin.Line, strings.TrimSpace(sourceLines[lineNumber-1])))
+
+	}
+
+	return output.String()
+
+}
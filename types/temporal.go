@@ -0,0 +1,234 @@
+package types
+
+import (
+	GPU "VoxelRPG/gpu"
+	Log "VoxelRPG/logging"
+	World "VoxelRPG/world"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/* -- [[ Temporal Reprojection ]] -- */
+
+// Runs after the raymarcher, reprojecting last frame's result onto the current one and blending
+// them, so sub-pixel jitter and per frame noise (soft shadows) average out over several frames.
+
+type temporalTarget struct {
+	fbo   uint32
+	color uint32
+	depth uint32
+}
+
+type TemporalAccumulation struct {
+	Enabled bool
+	Jitter  bool // Offset the camera rays by a sub-pixel amount each frame for anti-aliasing
+
+	HistoryWeight  float32
+	DepthRejection float32
+
+	targets [2]temporalTarget
+	current int
+
+	width, height int32
+
+	valid              bool
+	prevViewProjection mgl32.Mat4
+	prevCamPos         mgl32.Vec3
+
+	frame int
+}
+
+var TemporalAA = &TemporalAccumulation{
+	Enabled: true,
+	Jitter:  true,
+
+	HistoryWeight:  0.9,
+	DepthRejection: 0.05,
+}
+
+func newTemporalTarget(width, height int32) temporalTarget {
+
+	var target temporalTarget
+
+	target.color = newRenderTexture(gl.RGBA16F, gl.RGBA, gl.LINEAR, width, height)
+	target.depth = newRenderTexture(gl.R32F, gl.RED, gl.NEAREST, width, height)
+
+	gl.GenFramebuffers(1, &target.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, target.fbo)
+	GPU.Label(gl.FRAMEBUFFER, target.fbo, "Temporal History FBO")
+
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, target.color, 0)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT1, gl.TEXTURE_2D, target.depth, 0)
+
+	drawBuffers := []uint32{gl.COLOR_ATTACHMENT0, gl.COLOR_ATTACHMENT1}
+	gl.DrawBuffers(int32(len(drawBuffers)), &drawBuffers[0])
+
+	if err := checkFramebuffer("temporal history"); err != nil {
+		Log.GL.Error("Temporal history unavailable", "error", err)
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	return target
+
+}
+
+func (target *temporalTarget) delete() {
+
+	gl.DeleteFramebuffers(1, &target.fbo)
+	gl.DeleteTextures(1, &target.color)
+	gl.DeleteTextures(1, &target.depth)
+
+}
+
+// Resize recreates the history at the internal render resolution, dropping what was accumulated
+func (t *TemporalAccumulation) Resize(width, height int32) {
+
+	if width == t.width && height == t.height {
+		return
+	}
+
+	for i := range t.targets {
+
+		if t.targets[i].fbo != 0 {
+			t.targets[i].delete()
+		}
+
+		t.targets[i] = newTemporalTarget(width, height)
+
+	}
+
+	t.width = width
+	t.height = height
+	t.valid = false
+
+}
+
+// Delete frees the history targets, the next Resize creates them again
+func (t *TemporalAccumulation) Delete() {
+
+	for i := range t.targets {
+
+		if t.targets[i].fbo != 0 {
+			t.targets[i].delete()
+		}
+
+		t.targets[i] = temporalTarget{}
+
+	}
+
+	t.width, t.height = 0, 0
+	t.valid = false
+
+}
+
+func (t *TemporalAccumulation) Invalidate() {
+	t.valid = false
+}
+
+// Debug views show exactly what one frame traced, so nothing is accumulated while one is on
+func (t *TemporalAccumulation) active() bool {
+	return t.Enabled && DebugView == World.DebugViewOff
+}
+
+// JitterOffset is the sub-pixel offset for this frame, a Halton (2, 3) sequence centred on the pixel
+func (t *TemporalAccumulation) JitterOffset() mgl32.Vec2 {
+
+	if !t.active() || !t.Jitter {
+		return mgl32.Vec2{}
+	}
+
+	index := t.frame%8 + 1
+
+	return mgl32.Vec2{halton(index, 2) - 0.5, halton(index, 3) - 0.5}
+
+}
+
+func halton(index int, base int) float32 {
+
+	result := float32(0)
+	fraction := float32(1)
+
+	for index > 0 {
+		fraction /= float32(base)
+		result += fraction * float32(index%base)
+		index /= base
+	}
+
+	return result
+
+}
+
+// ViewProjection matches the rays built by the raymarcher, FOV is the vertical field of view in degrees
+func ViewProjection(view mgl32.Mat4, width, height float32) mgl32.Mat4 {
+
+	return mgl32.Perspective(mgl32.DegToRad(FOV), width/height, ZNear, ZFar).Mul4(view)
+
+}
+
+// Resolve blends the frame in the raymarcher FBO into the history, returning the texture to display
+func (t *TemporalAccumulation) Resolve(view mgl32.Mat4, camPos mgl32.Vec3, jitter mgl32.Vec2) uint32 {
+
+	defer func() { t.frame++ }()
+
+	if !t.active() || t.targets[0].fbo == 0 {
+		t.valid = false
+		return fboTexture
+	}
+
+	history := t.targets[t.current]
+	next := t.targets[1-t.current]
+
+	resolution := mgl32.Vec2{float32(t.width), float32(t.height)}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, next.fbo)
+	gl.Viewport(0, 0, t.width, t.height)
+
+	temporalShaderProgram.Use()
+	gl.BindVertexArray(screenVAO)
+
+	textures := []struct {
+		name    string
+		texture uint32
+	}{
+		{"currentColor", fboTexture},
+		{"currentDepth", fboDepthTexture},
+		{"historyColor", history.color},
+		{"historyDepth", history.depth},
+	}
+
+	for unit, input := range textures {
+		gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+		gl.BindTexture(gl.TEXTURE_2D, input.texture)
+		temporalShaderProgram.SetInt(input.name, int32(unit))
+	}
+
+	valid := int32(0)
+
+	if t.valid {
+		valid = 1
+	}
+
+	temporalShaderProgram.SetMat4("invView", view.Inv())
+	temporalShaderProgram.SetVec3("camPos", camPos)
+	temporalShaderProgram.SetVec2("iResolution", resolution)
+	temporalShaderProgram.SetVec2("jitter", jitter)
+	temporalShaderProgram.SetMat4("prevViewProjection", t.prevViewProjection)
+	temporalShaderProgram.SetVec3("prevCamPos", t.prevCamPos)
+	temporalShaderProgram.SetInt("historyValid", valid)
+	temporalShaderProgram.SetFloat("historyWeight", t.HistoryWeight)
+	temporalShaderProgram.SetFloat("depthRejection", t.DepthRejection)
+
+	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+
+	gl.ActiveTexture(gl.TEXTURE0)
+
+	t.current = 1 - t.current
+	t.valid = true
+	t.prevViewProjection = ViewProjection(view, resolution[0], resolution[1])
+	t.prevCamPos = camPos
+
+	return next.color
+
+}
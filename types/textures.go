@@ -47,3 +47,21 @@ func NewTexture(file string) (uint32, error) {
 
 	return texture, nil
 }
+
+// newRenderTexture allocates an empty texture for rendering into, filter is gl.LINEAR for colour
+// and gl.NEAREST for data such as depth that must not be blended between texels
+func newRenderTexture(internalFormat int32, format uint32, filter int32, width, height int32) uint32 {
+
+	var texture uint32
+
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, internalFormat, width, height, 0, format, gl.FLOAT, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, filter)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, filter)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+
+	return texture
+
+}
@@ -1,54 +1,349 @@
 package types
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	Client "VoxelRPG/client"
 	Log "VoxelRPG/logging"
+	Profiling "VoxelRPG/profiling"
 
 	"github.com/go-gl/glfw/v3.3/glfw"
 )
 
+// The raymarcher's shaders are #version 460 core, so the context has to be 4.6
+const (
+	GLVersionMajor = 4
+	GLVersionMinor = 6
+)
+
+type WindowMode int
+
+const (
+	WindowWindowed   WindowMode = iota
+	WindowFullscreen            // Exclusive, the monitor's current video mode
+	WindowBorderless            // Undecorated and covering the monitor, without a mode switch
+)
+
+var windowModeNames = [...]string{"windowed", "fullscreen", "borderless"}
+
+func (m WindowMode) String() string {
+	return windowModeNames[m]
+}
+
+type VSyncMode int
+
+const (
+	VSyncOn VSyncMode = iota
+	VSyncOff
+	VSyncAdaptive // Tears instead of waiting when a frame is late, falls back to on without driver support
+)
+
+var vsyncModeNames = [...]string{"on", "off", "adaptive"}
+
+func (m VSyncMode) String() string {
+	return vsyncModeNames[m]
+}
+
 type WindowBuilder struct {
-	Width  int
+	Width  int // Window size when creating it, the framebuffer size in pixels afterwards
 	Height int
 	Title  string
+
+	Mode       WindowMode
+	ToggleMode WindowMode // What FullscreenKey switches to from windowed, borderless when left windowed
+	Monitor    int        // Index into glfw.GetMonitors(), 0 is the primary monitor
+	VSync      VSyncMode
+
+	windowedX, windowedY          int // Where leaving fullscreen puts the window back
+	windowedWidth, windowedHeight int
+}
+
+// FullscreenKey toggles between windowed and WindowBuilder.ToggleMode
+var FullscreenKey = glfw.KeyF11
+
+/* -- [[ Settings ]] -- */
+
+func ParseWindowMode(name string) (WindowMode, error) {
+
+	for mode, modeName := range windowModeNames {
+		if strings.EqualFold(name, modeName) {
+			return WindowMode(mode), nil
+		}
+	}
+
+	return WindowWindowed, fmt.Errorf("unknown window mode %q", name)
+
+}
+
+func ParseVSyncMode(name string) (VSyncMode, error) {
+
+	for mode, modeName := range vsyncModeNames {
+		if strings.EqualFold(name, modeName) {
+			return VSyncMode(mode), nil
+		}
+	}
+
+	return VSyncOn, fmt.Errorf("unknown vsync mode %q", name)
+
 }
 
+// ApplyEnv reads VOXELRPG_WINDOW_MODE, VOXELRPG_MONITOR and VOXELRPG_VSYNC over the builder's settings
+func (b *WindowBuilder) ApplyEnv() {
+
+	if value := os.Getenv("VOXELRPG_WINDOW_MODE"); value != "" {
+
+		if mode, err := ParseWindowMode(value); err != nil {
+			Log.GL.Warn("Ignoring VOXELRPG_WINDOW_MODE", "error", err)
+		} else {
+			b.Mode = mode
+		}
+
+	}
+
+	if value := os.Getenv("VOXELRPG_MONITOR"); value != "" {
+
+		if monitor, err := strconv.Atoi(value); err != nil {
+			Log.GL.Warn("Ignoring VOXELRPG_MONITOR", "error", err)
+		} else {
+			b.Monitor = monitor
+		}
+
+	}
+
+	if value := os.Getenv("VOXELRPG_VSYNC"); value != "" {
+
+		if vsync, err := ParseVSyncMode(value); err != nil {
+			Log.GL.Warn("Ignoring VOXELRPG_VSYNC", "error", err)
+		} else {
+			b.VSync = vsync
+		}
+
+	}
+
+}
+
+/* -- [[ Creation ]] -- */
+
 func CreateWindow(builder *WindowBuilder) (*glfw.Window, error) {
 
-	Log.NewLog("Window - Creating..")
+	Log.GL.Info("Window - Creating..")
 
-	profiler := ProfilerStart("glfw_window")
+	profiler := Profiling.Start("window.create")
 
 	// Initialize the GLFW context
 
 	err := glfw.Init()
 
 	if err != nil {
-		Log.NewLog("Could not initialize GLFW")
+		Log.GL.Error("Could not initialize GLFW", "error", err)
 		return nil, err
 	}
 
 	// Setup Window Settings
 
 	glfw.WindowHint(glfw.Resizable, glfw.True)
-	glfw.WindowHint(glfw.ContextVersionMajor, 4)
-	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	glfw.WindowHint(glfw.ContextVersionMajor, GLVersionMajor)
+	glfw.WindowHint(glfw.ContextVersionMinor, GLVersionMinor)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
 	glfw.WindowHint(glfw.ScaleToMonitor, glfw.True)
 
+	if GLDebug.Enabled {
+		glfw.WindowHint(glfw.OpenGLDebugContext, glfw.True)
+	}
+
 	window, err := glfw.CreateWindow(builder.Width, builder.Height, builder.Title, nil, nil)
 
 	if err != nil {
-		Log.NewLog("Could not create window")
+
+		var glfwErr *glfw.Error
+
+		if errors.As(err, &glfwErr) && (glfwErr.Code == glfw.VersionUnavailable || glfwErr.Code == glfw.APIUnavailable) {
+			err = &ContextError{Major: GLVersionMajor, Minor: GLVersionMinor, Err: err}
+		}
+
+		Log.GL.Error("Could not create window", "error", err)
 		glfw.Terminate()
 		return nil, err
 
 	}
 
-	TimeTook := profiler.EndProfiler(profiler)
+	builder.windowedX, builder.windowedY = window.GetPos()
+	builder.windowedWidth, builder.windowedHeight = window.GetSize()
 
-	Log.NewLog("Window - Created ( Time:", TimeTook, ")\n")
+	if builder.Mode != WindowWindowed {
+
+		if builder.ToggleMode == WindowWindowed {
+			builder.ToggleMode = builder.Mode
+		}
+
+		mode := builder.Mode
+		builder.Mode = WindowWindowed
+
+		applyWindowMode(window, builder, mode)
+
+	}
+
+	TimeTook := profiler.End()
+
+	scaleX, scaleY := window.GetContentScale()
+
+	Log.GL.Info("Window - Created", "time", TimeTook, "mode", builder.Mode, "contentScale", fmt.Sprintf("%vx%v", scaleX, scaleY))
 
 	return window, nil
 
 }
+
+/* -- [[ Monitors ]] -- */
+
+// selectMonitor returns the monitor at index, or the primary one when it is not connected
+func selectMonitor(index int) *glfw.Monitor {
+
+	monitors := glfw.GetMonitors()
+
+	if index >= 0 && index < len(monitors) {
+		return monitors[index]
+	}
+
+	Log.GL.Warn("Monitor not connected, using the primary monitor", "monitor", index, "connected", len(monitors))
+
+	return glfw.GetPrimaryMonitor()
+
+}
+
+/* -- [[ Modes ]] -- */
+
+// applyWindowMode must run on the main thread, GLFW only changes windows from there
+func applyWindowMode(window *glfw.Window, builder *WindowBuilder, mode WindowMode) {
+
+	if builder.Mode == WindowWindowed && mode != WindowWindowed {
+		builder.windowedX, builder.windowedY = window.GetPos()
+		builder.windowedWidth, builder.windowedHeight = window.GetSize()
+	}
+
+	var monitor *glfw.Monitor
+
+	if mode != WindowWindowed {
+
+		if monitor = selectMonitor(builder.Monitor); monitor == nil {
+			Log.GL.Warn("No monitor found, staying windowed", "mode", mode)
+			return
+		}
+
+	}
+
+	switch mode {
+	case WindowFullscreen:
+
+		video := monitor.GetVideoMode()
+
+		window.SetAttrib(glfw.Decorated, glfw.True)
+		window.SetMonitor(monitor, 0, 0, video.Width, video.Height, video.RefreshRate)
+
+	case WindowBorderless:
+
+		video := monitor.GetVideoMode()
+		x, y := monitor.GetPos()
+
+		window.SetAttrib(glfw.Decorated, glfw.False)
+		window.SetMonitor(nil, x, y, video.Width, video.Height, 0)
+
+	default:
+
+		window.SetMonitor(nil, builder.windowedX, builder.windowedY, builder.windowedWidth, builder.windowedHeight, 0)
+		window.SetAttrib(glfw.Decorated, glfw.True)
+
+	}
+
+	builder.Mode = mode
+
+	// The framebuffer size is picked up by OpenGLFixedUpdate on the render thread
+
+	Log.GL.Info("Window mode changed", "mode", mode)
+
+}
+
+// ToggleFullscreen switches between windowed and builder.ToggleMode, must run on the main thread
+func ToggleFullscreen(window *glfw.Window, builder *WindowBuilder) {
+
+	if builder.Mode != WindowWindowed {
+		applyWindowMode(window, builder, WindowWindowed)
+		return
+	}
+
+	mode := builder.ToggleMode
+
+	if mode == WindowWindowed {
+		mode = WindowBorderless
+	}
+
+	applyWindowMode(window, builder, mode)
+
+}
+
+// SetVSync sets the swap interval, it needs the context current on the calling thread
+func SetVSync(mode VSyncMode) {
+
+	switch mode {
+	case VSyncOff:
+		glfw.SwapInterval(0)
+	case VSyncAdaptive:
+
+		if glfw.ExtensionSupported("GLX_EXT_swap_control_tear") || glfw.ExtensionSupported("WGL_EXT_swap_control_tear") {
+			glfw.SwapInterval(-1)
+			break
+		}
+
+		Log.GL.Warn("Adaptive vsync is not supported, using vsync")
+		glfw.SwapInterval(1)
+
+	default:
+		glfw.SwapInterval(1)
+	}
+
+}
+
+/* -- [[ Main Thread ]] -- */
+
+// windowTasks are window changes asked for from the render thread, run by the event loop in main
+var windowTasks = make(chan func(), 16)
+
+// RunOnMainThread queues a task for RunWindowTasks and wakes the event loop
+func RunOnMainThread(task func()) {
+
+	select {
+	case windowTasks <- task:
+		glfw.PostEmptyEvent()
+	default:
+		Log.GL.Warn("Window task queue full, dropping the request")
+	}
+
+}
+
+// RunWindowTasks runs the queued window changes, called by the event loop on the main thread
+func RunWindowTasks() {
+
+	for {
+		select {
+		case task := <-windowTasks:
+			task()
+		default:
+			return
+		}
+	}
+
+}
+
+func SetupWindowKeybinds(client *Client.ClientContext, window *glfw.Window, builder *WindowBuilder) {
+
+	Client.CBOnKeyChange(client, FullscreenKey, func(action glfw.Action) {
+		if action == glfw.Press {
+			RunOnMainThread(func() { ToggleFullscreen(window, builder) })
+		}
+	})
+
+}
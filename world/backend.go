@@ -0,0 +1,194 @@
+package world
+
+import (
+	"fmt"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/* -- [[ Render Backend ]] -- */
+
+// RenderBackend is everything the world needs from the renderer. The OpenGL backend lives in the
+// types package, RecordingBackend keeps everything in memory so the world runs without a GPU.
+type RenderBackend interface {
+	AllocateNodes(nodes int) error                      // Sizes the octree node buffer, dropping its contents
+	UploadNodes(offset uint32, nodes []GridNodeFlatGPU) // Offsets count nodes, not bytes
+	ClearNodes(offset uint32, count int)
+	UploadChunkIndex(displacements []uint32, entries []MapEntry)
+	UploadMaterials(materials []MaterialGPU)
+	UploadLights(lights []LightGPU)
+	SetWorldParams(params WorldParams)
+	DrawFrame(frame FrameParams)
+	Close()
+}
+
+// WorldParams are the uniforms that only change with the world
+type WorldParams struct {
+	ChunkSize  float32
+	ChunkScale float32
+	Lights     uint32 // Lights in use, the uploaded table holds a placeholder when there are none
+}
+
+// FrameParams is one raymarched view of the world
+type FrameParams struct {
+	Width, Height int32 // Pixels drawn into the scene target
+	Resolution    mgl32.Vec2
+	Viewport      mgl32.Vec4 // Part of the full image this draw covers, for tiled screenshots
+
+	InvView   mgl32.Mat4
+	CameraPos mgl32.Vec3
+	Jitter    mgl32.Vec2
+	Time      float32
+
+	FOV       float32 // Vertical, in degrees
+	DebugView DebugView
+
+	MaxTransparencyDepth int32
+	MaxLightsPerHit      int32
+}
+
+/* -- [[ Recording Backend ]] -- */
+
+// BackendCall is one call made on a RecordingBackend
+type BackendCall struct {
+	Method string
+	Offset uint32 // Node offset for node calls
+	Count  int    // Nodes, entries or lights passed
+}
+
+// RecordingBackend stores uploads in memory and records every call, so world streaming and
+// uploads can be checked headless. Writes outside the allocated nodes are kept in Errors,
+// where the GL backend would raise GL_INVALID_VALUE.
+type RecordingBackend struct {
+	Calls  []BackendCall
+	Errors []error
+
+	Nodes         []GridNodeFlatGPU
+	Displacements []uint32
+	Entries       []MapEntry
+	Materials     []MaterialGPU
+	Lights        []LightGPU
+	Params        WorldParams
+	Frames        []FrameParams
+
+	Closed bool
+}
+
+func NewRecordingBackend() *RecordingBackend {
+	return &RecordingBackend{}
+}
+
+func (b *RecordingBackend) record(method string, offset uint32, count int) {
+	b.Calls = append(b.Calls, BackendCall{Method: method, Offset: offset, Count: count})
+}
+
+// CallsTo returns the recorded calls of one method, in order
+func (b *RecordingBackend) CallsTo(method string) []BackendCall {
+
+	var calls []BackendCall
+
+	for _, call := range b.Calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+
+}
+
+func (b *RecordingBackend) AllocateNodes(nodes int) error {
+
+	b.record("AllocateNodes", 0, nodes)
+
+	if nodes < 0 {
+		return fmt.Errorf("cannot allocate %d octree nodes", nodes)
+	}
+
+	b.Nodes = make([]GridNodeFlatGPU, nodes)
+
+	return nil
+
+}
+
+func (b *RecordingBackend) writeNodes(method string, offset uint32, count int) []GridNodeFlatGPU {
+
+	b.record(method, offset, count)
+
+	// The GL backend writes whatever it is given, so empty or unplaced writes are caller bugs
+
+	if count <= 0 || offset == MaxUINT32 {
+		b.Errors = append(b.Errors, fmt.Errorf("%v of %d nodes at %d has no nodes or no chunk offset", method, count, offset))
+		return nil
+	}
+
+	end := int(offset) + count
+
+	if end > len(b.Nodes) {
+		b.Errors = append(b.Errors, fmt.Errorf("%v of %d nodes at %d overflows the %d allocated", method, count, offset, len(b.Nodes)))
+		return nil
+	}
+
+	return b.Nodes[offset:end]
+
+}
+
+func (b *RecordingBackend) UploadNodes(offset uint32, nodes []GridNodeFlatGPU) {
+
+	if target := b.writeNodes("UploadNodes", offset, len(nodes)); target != nil {
+		copy(target, nodes)
+	}
+
+}
+
+func (b *RecordingBackend) ClearNodes(offset uint32, count int) {
+
+	if target := b.writeNodes("ClearNodes", offset, count); target != nil {
+		clear(target)
+	}
+
+}
+
+func (b *RecordingBackend) UploadChunkIndex(displacements []uint32, entries []MapEntry) {
+
+	b.record("UploadChunkIndex", 0, len(entries))
+
+	b.Displacements = append(b.Displacements[:0], displacements...)
+	b.Entries = append(b.Entries[:0], entries...)
+
+}
+
+func (b *RecordingBackend) UploadMaterials(materials []MaterialGPU) {
+
+	b.record("UploadMaterials", 0, len(materials))
+	b.Materials = append(b.Materials[:0], materials...)
+
+}
+
+func (b *RecordingBackend) UploadLights(lights []LightGPU) {
+
+	b.record("UploadLights", 0, len(lights))
+	b.Lights = append(b.Lights[:0], lights...)
+
+}
+
+func (b *RecordingBackend) SetWorldParams(params WorldParams) {
+
+	b.record("SetWorldParams", 0, 0)
+	b.Params = params
+
+}
+
+func (b *RecordingBackend) DrawFrame(frame FrameParams) {
+
+	b.record("DrawFrame", 0, 0)
+	b.Frames = append(b.Frames, frame)
+
+}
+
+func (b *RecordingBackend) Close() {
+
+	b.record("Close", 0, 0)
+	b.Closed = true
+
+}
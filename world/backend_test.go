@@ -0,0 +1,249 @@
+package world
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// checkChunkUploads expects one UploadNodes per chunk, at the chunk's offset with its whole octree
+func checkChunkUploads(t *testing.T, w *World, backend *RecordingBackend) {
+
+	t.Helper()
+
+	uploads := map[uint32]BackendCall{}
+
+	for _, call := range backend.CallsTo("UploadNodes") {
+
+		if _, ok := uploads[call.Offset]; ok {
+			t.Errorf("two uploads at offset %d", call.Offset)
+		}
+
+		uploads[call.Offset] = call
+
+	}
+
+	if len(uploads) != len(w.Chunks) {
+		t.Errorf("%d chunks uploaded, want %d", len(uploads), len(w.Chunks))
+	}
+
+	for _, chunk := range w.Chunks {
+
+		call, ok := uploads[chunk.OctreeOffset]
+
+		if !ok || call.Count != len(w.octrees[chunk]) {
+			t.Errorf("chunk %v: upload %+v, want %d nodes at offset %d", chunk.Position, call, len(w.octrees[chunk]), chunk.OctreeOffset)
+		}
+
+	}
+
+	if len(backend.Errors) != 0 {
+		t.Errorf("backend errors: %v", backend.Errors)
+	}
+
+}
+
+// loadedEntries counts the filled slots of the uploaded chunk index, checking each points at its chunk
+func loadedEntries(t *testing.T, w *World, backend *RecordingBackend) int {
+
+	t.Helper()
+
+	loaded := 0
+
+	for _, entry := range backend.Entries {
+
+		if entry.RootOffset == math.MaxUint32 {
+			continue
+		}
+
+		if chunk := w.ChunkMap[entry.Position]; chunk == nil || entry.RootOffset != chunk.OctreeOffset {
+			t.Errorf("chunk index entry %+v does not point at a loaded chunk", entry)
+		}
+
+		loaded++
+
+	}
+
+	return loaded
+
+}
+
+func TestPopulateUploadsEveryChunk(t *testing.T) {
+
+	w, backend := newRecordedWorld(t)
+	defer w.Close()
+
+	if err := w.Populate(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkChunkUploads(t, w, backend)
+
+	if err := w.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := backend.CallsTo("UploadChunkIndex"); len(calls) != 1 {
+		t.Errorf("chunk index uploads %+v, want one", calls)
+	}
+
+	if entries := loadedEntries(t, w, backend); entries != len(w.Chunks) {
+		t.Errorf("chunk index has %d chunks, want %d", entries, len(w.Chunks))
+	}
+
+}
+
+func TestFlushDirtyChunksReuploadsInPlace(t *testing.T) {
+
+	w, backend := newRecordedWorld(t)
+	defer w.Close()
+
+	if err := w.Populate(); err != nil {
+		t.Fatal(err)
+	}
+
+	chunk := w.ChunkMap[Vec3{}]
+	before := append([]GridNodeFlatGPU(nil), backend.Nodes[chunk.OctreeOffset:int(chunk.OctreeOffset)+len(w.octrees[chunk])]...)
+
+	backend.Calls = nil
+
+	// Flip one voxel, clearing it if generation filled it and filling it otherwise
+
+	voxel := Vec3{1, 1, 1}
+	material := MaterialDefault
+
+	if w.VoxelOccupied(voxel) {
+		material = MaterialAir
+	}
+
+	if !w.SetVoxel(voxel, material) {
+		t.Fatal("SetVoxel missed the loaded chunk")
+	}
+
+	if uploads := backend.CallsTo("UploadNodes"); len(uploads) != 0 {
+		t.Errorf("SetVoxel uploaded %+v before the flush", uploads)
+	}
+
+	w.FlushDirtyChunks()
+
+	uploads := backend.CallsTo("UploadNodes")
+
+	if len(uploads) != 1 || uploads[0].Offset != chunk.OctreeOffset || uploads[0].Count != len(w.octrees[chunk]) {
+		t.Fatalf("flush uploaded %+v, want %d nodes at offset %d", uploads, len(w.octrees[chunk]), chunk.OctreeOffset)
+	}
+
+	after := backend.Nodes[chunk.OctreeOffset : int(chunk.OctreeOffset)+len(w.octrees[chunk])]
+
+	changed := len(before) != len(after)
+
+	for i := 0; !changed && i < len(before); i++ {
+		changed = before[i] != after[i]
+	}
+
+	if !changed {
+		t.Error("the uploaded octree did not change after the edit")
+	}
+
+	if len(backend.Errors) != 0 {
+		t.Errorf("backend errors: %v", backend.Errors)
+	}
+
+}
+
+func TestSetRenderDistanceRepopulates(t *testing.T) {
+
+	w, backend := newRecordedWorld(t)
+	defer w.Close()
+
+	if err := w.Populate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.SetRenderDistance(MaxRenderDistance() + 1); !errors.Is(err, ErrInvalidRenderDistance) {
+		t.Errorf("too far a render distance returned %v, want ErrInvalidRenderDistance", err)
+	}
+
+	backend.Calls = nil
+
+	if err := w.SetRenderDistance(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(w.Chunks) != 1 || w.Chunks[0].OctreeOffset != 0 {
+		t.Fatalf("render distance 1 loaded %d chunks, want one at offset 0", len(w.Chunks))
+	}
+
+	checkChunkUploads(t, w, backend)
+
+	if entries := loadedEntries(t, w, backend); entries != 1 {
+		t.Errorf("chunk index has %d chunks, want 1", entries)
+	}
+
+}
+
+func TestSetRenderDistanceKeepsWorldWhenStopped(t *testing.T) {
+
+	w, backend := newRecordedWorld(t)
+	defer w.Close()
+
+	if err := w.Populate(); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := append([]*Chunk(nil), w.Chunks...)
+	nodes := w.OctreeNodes()
+
+	backend.Calls = nil
+
+	w.StopGeneration()
+
+	if err := w.SetRenderDistance(1); !errors.Is(err, ErrGenerationStopped) {
+		t.Fatalf("stopped SetRenderDistance returned %v, want ErrGenerationStopped", err)
+	}
+
+	if *w.RenderDistance != 2 || len(w.Chunks) != len(chunks) || w.OctreeNodes() != nodes {
+		t.Errorf("stopped change left distance %d, %d chunks and %d nodes, want 2, %d and %d", *w.RenderDistance, len(w.Chunks), w.OctreeNodes(), len(chunks), nodes)
+	}
+
+	for i, chunk := range w.Chunks {
+		if chunk != chunks[i] || w.ChunkMap[chunk.Position] != chunk || len(w.Octree(chunk)) != NodesRequired {
+			t.Errorf("chunk %d was replaced or lost its octree", i)
+		}
+	}
+
+	if len(backend.Calls) != 0 {
+		t.Errorf("stopped change called the backend: %+v", backend.Calls)
+	}
+
+}
+
+func TestRecordingBackendRejectsBadWrites(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		write func(b *RecordingBackend)
+	}{
+		{"empty upload", func(b *RecordingBackend) { b.UploadNodes(0, nil) }},
+		{"unplaced upload", func(b *RecordingBackend) { b.UploadNodes(MaxUINT32, make([]GridNodeFlatGPU, 1)) }},
+		{"overflowing upload", func(b *RecordingBackend) { b.UploadNodes(0, make([]GridNodeFlatGPU, 5)) }},
+		{"empty clear", func(b *RecordingBackend) { b.ClearNodes(0, 0) }},
+		{"unplaced clear", func(b *RecordingBackend) { b.ClearNodes(MaxUINT32, 1) }},
+	}
+
+	for _, test := range tests {
+
+		backend := NewRecordingBackend()
+
+		if err := backend.AllocateNodes(4); err != nil {
+			t.Fatal(err)
+		}
+
+		test.write(backend)
+
+		if len(backend.Errors) != 1 {
+			t.Errorf("%v: backend errors %v, want one", test.name, backend.Errors)
+		}
+
+	}
+
+}
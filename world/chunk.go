@@ -1,32 +1,55 @@
 package world
 
 import (
-	"math"
 	"math/rand"
 	"sync"
 	"time"
-	"unsafe"
 
 	Log "VoxelRPG/logging"
+	Profiling "VoxelRPG/profiling"
 
-	"github.com/go-gl/gl/v4.6-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
-//"github.com/go-gl/gl/v4.6-core/gl"
-
 func IsBlockFull(x, y, z int) bool {
 
 	return rand.Float32() > 0.5
 
 }
 
-func VoxelMetadata(idx int) GridMetadata {
+func VoxelMaterial(x, y, z int) MaterialID {
+
+	return MaterialDefault
+
+}
+
+// Colours are hashed from the node so rebuilding an edited chunk keeps them stable
+func VoxelMetadata(seed uint32) GridMetadata {
+
+	h := hashUint32(seed)
+
+	return GridMetadata{
+		R:        h % 256,
+		G:        (h >> 8) % 256,
+		B:        (h >> 16) % 256,
+		Material: uint32(MaterialDefault),
+	}
+
+}
+
+func MaterialMetadata(seed uint32, material MaterialID) GridMetadata {
+
+	if material == MaterialDefault {
+		return VoxelMetadata(seed)
+	}
+
+	m := material.Material()
 
 	return GridMetadata{
-		R: rand.Uint32() % 256,
-		G: rand.Uint32() % 256,
-		B: rand.Uint32() % 256,
+		R:        uint32(m.R),
+		G:        uint32(m.G),
+		B:        uint32(m.B),
+		Material: uint32(material),
 	}
 
 }
@@ -36,76 +59,79 @@ func NewChunk(WorldPosition Vec3) *Chunk {
 	outputChunk := Chunk{
 		Position:     WorldPosition,
 		Voxels:       make([]uint8, (FULL_CHUNK_SIZE+7)/8),
+		Materials:    make([]MaterialID, FULL_CHUNK_SIZE),
 		OctreeOffset: MaxUINT32,
 	}
 
-	StartedChunkGen := time.Now()
+	generate := Profiling.Start("chunk.generate")
 
 	outputChunk.GenerateVoxelData()
 
-	Log.NewLog("Length:", len(outputChunk.Voxels))
+	generate.End()
 
-	Log.NewLog("Chunk generation took:", time.Since(StartedChunkGen))
+	return &outputChunk
 
-	StartedOctreeGen := time.Now()
+}
 
-	outputChunk.SetupOctree()
+// SetupOctree builds the chunk's octree, the world stores it and places it in the node buffer
+func (chunk *Chunk) SetupOctree() []GridNodeFlatGPU {
 
-	Log.NewLog("Octree generation took:", time.Since(StartedOctreeGen))
+	defer Profiling.Start("chunk.octree").End()
 
-	return &outputChunk
+	return chunk.BuildNestedGrid()
 
 }
 
-func (chunk *Chunk) SetupOctree() {
+// uploadLog is hit once per chunk while a world loads
+var uploadLog = Log.World.Every(time.Second)
 
-	CombinedOctreeChan <- OctreeChanInput{
-		Input: chunk,
-		Value: chunk.BuildNestedGrid(),
-	}
+// UploadChunk sends a loaded chunk's stored octree to the backend at the chunk's offset
+func (w *World) UploadChunk(chunk *Chunk) {
+
+	uploadLog.Debug("Uploading chunk octree", "position", chunk.Position, "offset", chunk.OctreeOffset)
+
+	chunk.UploadNodes(w.Backend, w.octrees[chunk])
 
 }
 
-func (chunk *Chunk) RemoveOctree() {
-	CombinedOctreeLength -= uint32(len(CombinedOctree[chunk]))
-	chunk.OctreeOffset = math.MaxUint32
-	CombinedOctree[chunk] = nil
+func (chunk *Chunk) UploadNodes(backend RenderBackend, nodes []GridNodeFlatGPU) {
+
+	defer Profiling.Start("chunk.upload").End()
+
+	backend.UploadNodes(chunk.OctreeOffset, nodes)
+
 }
 
-func (chunk *Chunk) Upload() {
+// Rebuild regenerates the octree after voxel edits and re-uploads it in place to the world's backend
+func (chunk *Chunk) Rebuild(w *World) {
+
+	defer Profiling.Start("chunk.rebuild").End()
 
-	ssboOffsetBytes := int(chunk.OctreeOffset) * int(OctreeNodeByteSize)
-	data := unsafe.Pointer(&CombinedOctree[chunk][0])
+	nodes := chunk.SetupOctree()
 
-	Log.NewLog("Uploading new data, Total:", chunk.OctreeOffset)
+	w.bakeLight(chunk, nodes)
+	w.storeOctree(chunk, nodes)
 
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, MainWorld.CombinedSSBO)
-	gl.BufferSubData(gl.SHADER_STORAGE_BUFFER, ssboOffsetBytes, len(CombinedOctree[chunk])*OctreeNodeByteSize, data)
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+	chunk.UploadNodes(w.Backend, nodes)
+	chunk.Dirty = false
 
 }
 
-func (chunk *Chunk) Unload() {
+// UnloadChunk clears a chunk's nodes in the backend and drops its octree
+func (w *World) UnloadChunk(chunk *Chunk) {
 
-	if len(CombinedOctree[chunk]) <= 0 {
-		Log.NewLog("Chunk already unloaded")
+	nodes := len(w.octrees[chunk])
+
+	if nodes == 0 {
+		Log.World.Warn("Chunk already unloaded", "position", chunk.Position)
 		return
 	}
 
-	sizeBytes := len(CombinedOctree[chunk]) * OctreeNodeByteSize
-
-	zeroBytes := make([]byte, sizeBytes)
-	zeroData := unsafe.Pointer(&zeroBytes[0])
+	Log.World.Debug("Unloading chunk", "offset", chunk.OctreeOffset, "nodes", nodes)
 
-	ssboOffsetBytes := int(chunk.OctreeOffset) * int(OctreeNodeByteSize)
+	w.Backend.ClearNodes(chunk.OctreeOffset, nodes)
+	w.RemoveOctree(chunk)
 
-	Log.NewLog("Offset", chunk.OctreeOffset, (int(chunk.OctreeOffset) * int(OctreeNodeByteSize)), len(CombinedOctree[chunk])*OctreeNodeByteSize)
-
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, MainWorld.CombinedSSBO)
-	gl.BufferSubData(gl.SHADER_STORAGE_BUFFER, ssboOffsetBytes, sizeBytes, zeroData)
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
-
-	CombinedOctree[chunk] = nil
 }
 
 func (chunk *Chunk) IsVisible(viewProjection mgl32.Mat4) bool {
@@ -164,6 +190,10 @@ func (chunk *Chunk) GenerateVoxelData() {
 
 				chunkSetVoxelBit(chunk.Voxels, voxelIndex, isFull)
 
+				if isFull {
+					chunk.Materials[voxelIndex] = VoxelMaterial(int(m.X)+x, int(m.Y)+y, int(m.Z)+z)
+				}
+
 			}
 
 		}(start, end)
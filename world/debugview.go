@@ -0,0 +1,167 @@
+package world
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/* -- [[ Debug Views ]] -- */
+
+// Replace the shaded image with information about the traversal. The colours here must match
+// debugColor in octree_traverse.frag. The reference path walks voxels with a DDA instead of the
+// octree and has no LOD cutoff, so its step counts are DDA steps and every hit is a leaf.
+
+type DebugView int32
+
+const (
+	DebugViewOff      DebugView = iota
+	DebugViewSteps              // Traversal steps per pixel as a heatmap
+	DebugViewDepth              // Octree depth of the node that was hit
+	DebugViewChunks             // Colour per chunk position with the chunk borders darkened
+	DebugViewNormals            // Face normal of the hit
+	DebugViewDistance           // Distance to the hit, white up close
+	DebugViewLOD                // Nodes the LOD cutoff stopped at in magenta
+
+	DebugViewCount
+)
+
+const DebugStepScale = 128 // Step count shown as the hottest colour
+
+var debugViewNames = [DebugViewCount]string{"off", "steps", "depth", "chunks", "normals", "distance", "lod"}
+
+func (v DebugView) String() string {
+
+	if v < 0 || v >= DebugViewCount {
+		return "unknown"
+	}
+
+	return debugViewNames[v]
+
+}
+
+// Next cycles through the views, wrapping back to off
+func (v DebugView) Next() DebugView {
+	return (v + 1) % DebugViewCount
+}
+
+// DebugHeatmap maps 0..1 from blue through green to red
+func DebugHeatmap(value float32) mgl32.Vec3 {
+
+	value = clampF32(value, 0, 1)
+
+	channel := func(centre float32) float32 {
+		return clampF32(1.5-float32(math.Abs(float64(4*value-centre))), 0, 1)
+	}
+
+	return mgl32.Vec3{channel(3), channel(2), channel(1)}
+
+}
+
+func DebugChunkColor(chunk Vec3) mgl32.Vec3 {
+
+	h := hash3D([3]int32{chunk.X, chunk.Y, chunk.Z})
+
+	return mgl32.Vec3{
+		0.3 + 0.7*float32(h&255)/255,
+		0.3 + 0.7*float32((h>>8)&255)/255,
+		0.3 + 0.7*float32((h>>16)&255)/255,
+	}
+
+}
+
+// debugShade is the flat grey the LOD view draws ordinary hits with
+func debugShade(normal Vec3) mgl32.Vec3 {
+
+	shade := 0.45 + 0.25*float32(normal.Y) + 0.1*float32(normal.X)
+
+	return mgl32.Vec3{shade, shade, shade}
+
+}
+
+// debugColor is the colour of a primary ray in a debug view, hit is only used when ok is set
+func (w *World) debugColor(view DebugView, hit RayHit, ok bool) mgl32.Vec3 {
+
+	if view == DebugViewSteps {
+		return DebugHeatmap(float32(hit.Steps) / DebugStepScale)
+	}
+
+	if !ok {
+		return mgl32.Vec3{}
+	}
+
+	switch view {
+
+	case DebugViewDepth:
+		return DebugHeatmap(1) // Without LOD every hit is a leaf at the deepest level
+
+	case DebugViewChunks:
+
+		size := int32(CHUNK_SIZE)
+		chunk := Vec3{X: floorDiv(hit.Voxel.X, size), Y: floorDiv(hit.Voxel.Y, size), Z: floorDiv(hit.Voxel.Z, size)}
+
+		// Distance in voxels to the nearest chunk edge across the face
+
+		local := hit.Position.Mul(1 / (float32(CHUNK_SIZE) * CHUNK_SCALE))
+		edge := float32(math.Inf(1))
+
+		for axis, n := range [3]int32{hit.Normal.X, hit.Normal.Y, hit.Normal.Z} {
+
+			if n != 0 {
+				continue
+			}
+
+			f := local[axis] - float32(math.Floor(float64(local[axis])))
+			edge = min(edge, min(f, 1-f)*float32(CHUNK_SIZE))
+
+		}
+
+		if edge < 0.5 {
+			return DebugChunkColor(chunk).Mul(0.25)
+		}
+
+		return DebugChunkColor(chunk)
+
+	case DebugViewNormals:
+		return mgl32.Vec3{float32(hit.Normal.X), float32(hit.Normal.Y), float32(hit.Normal.Z)}.Mul(0.5).Add(mgl32.Vec3{0.5, 0.5, 0.5})
+
+	case DebugViewDistance:
+		shade := 1 - clampF32(hit.T/ReferenceMaxDistance, 0, 1)
+		return mgl32.Vec3{shade, shade, shade}
+
+	case DebugViewLOD:
+		return debugShade(hit.Normal)
+
+	}
+
+	return mgl32.Vec3{}
+
+}
+
+// RenderDebugView draws a debug view of the world from the camera for offline inspection
+func (w *World) RenderDebugView(cam ReferenceCamera, width, height int, view DebugView) *image.RGBA {
+
+	output := image.NewRGBA(image.Rect(0, 0, width, height))
+	invView := cam.InvView()
+
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+
+			hit, ok := w.TraceRay(cam.Pos, CameraRay(invView, px, py, width, height), ReferenceMaxDistance)
+			c := w.debugColor(view, hit, ok)
+
+			output.SetRGBA(px, py, color.RGBA{
+				uint8(clampF32(c[0], 0, 1) * 255),
+				uint8(clampF32(c[1], 0, 1) * 255),
+				uint8(clampF32(c[2], 0, 1) * 255),
+				255,
+			})
+
+		}
+	}
+
+	return output
+
+}
@@ -0,0 +1,13 @@
+package world
+
+import "errors"
+
+var (
+	ErrGenerationStopped     = errors.New("world generation stopped")
+	ErrInvalidRenderDistance = errors.New("render distance out of range")
+	ErrNoBackend             = errors.New("world has no render backend")
+
+	// Chunk lookup table failures, the GPU keeps the previous table when these happen
+	ErrNoChunks       = errors.New("no chunks to build a lookup table for")
+	ErrNoDisplacement = errors.New("no displacement found for a chunk lookup bucket")
+)
@@ -0,0 +1,241 @@
+package world
+
+import (
+	GPU "VoxelRPG/gpu"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//go:generate go run ../tools/glslgen -out ../shaders/include/gpu_types.glsl
+
+/* -- [[ GLSL Layout Generation ]] -- */
+
+// Structs uploaded to SSBOs are mirrored in shaders/include/gpu_types.glsl, generated from the
+// Go types below. Field names come from the glsl struct tag, offsets are checked against std430.
+
+type GPUStruct struct {
+	Name string // Name of the GLSL struct
+	Type reflect.Type
+}
+
+type GPUConstant struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+var GPUStructs = []GPUStruct{
+	{"GridMetadata", reflect.TypeFor[GridMetadata]()},
+	{"GridNodeFlat", reflect.TypeFor[GridNodeFlatGPU]()},
+	{"ChunkInfo", reflect.TypeFor[MapEntry]()},
+	{"Material", reflect.TypeFor[MaterialGPU]()},
+	{"Light", reflect.TypeFor[LightGPU]()},
+}
+
+func GPUConstants() []GPUConstant {
+
+	var constants []GPUConstant
+
+	for _, binding := range GPU.SSBOBindings {
+		constants = append(constants, GPUConstant{binding.Name, "int", fmt.Sprint(binding.Binding)})
+	}
+
+	return append(constants, []GPUConstant{
+		{"HASH_MIX_A", "uint", fmt.Sprintf("0x%08Xu", hashMixA)},
+		{"HASH_MIX_B", "uint", fmt.Sprintf("0x%08Xu", hashMixB)},
+		{"HASH_GOLDEN", "uint", fmt.Sprintf("0x%08Xu", hashGolden)},
+		{"HASH_SIGN_OFFSET", "uint", fmt.Sprintf("0x%08Xu", hashSignOffset)},
+		{"HASH2_MULTIPLIER", "ivec3", fmt.Sprintf("ivec3(0x%08X, 0x%08X, 0x%08X)", hash2Multiplier[0], hash2Multiplier[1], hash2Multiplier[2])},
+	}...)
+
+}
+
+type glslField struct {
+	Name   string
+	Type   string
+	Length int // Array length, 0 for non arrays
+	Offset uintptr
+}
+
+type glslLayout struct {
+	Align  uintptr
+	Size   uintptr
+	Fields []glslField
+}
+
+func roundUp(value, align uintptr) uintptr {
+	return (value + align - 1) / align * align
+}
+
+func gpuStructName(t reflect.Type) (string, bool) {
+
+	for _, s := range GPUStructs {
+		if s.Type == t {
+			return s.Name, true
+		}
+	}
+
+	return "", false
+
+}
+
+// glslType returns the GLSL type of a Go type with its std430 alignment and size
+func glslType(t reflect.Type) (name string, length int, align uintptr, size uintptr, err error) {
+
+	switch t.Kind() {
+
+	case reflect.Uint32:
+		return "uint", 0, 4, 4, nil
+
+	case reflect.Int32:
+		return "int", 0, 4, 4, nil
+
+	case reflect.Float32:
+		return "float", 0, 4, 4, nil
+
+	case reflect.Array:
+
+		// Three component arrays of floats are vectors, anything else stays an array
+
+		if t.Len() == 3 && t.Elem().Kind() == reflect.Float32 {
+			return "vec3", 0, 16, 12, nil
+		}
+
+		elem, elemLength, elemAlign, elemSize, err := glslType(t.Elem())
+
+		if err != nil {
+			return "", 0, 0, 0, err
+		}
+
+		if elemLength != 0 {
+			return "", 0, 0, 0, fmt.Errorf("nested array %v is not supported", t)
+		}
+
+		return elem, t.Len(), elemAlign, roundUp(elemSize, elemAlign) * uintptr(t.Len()), nil
+
+	case reflect.Struct:
+
+		if t == reflect.TypeFor[Vec3]() {
+			return "ivec3", 0, 16, 12, nil
+		}
+
+		structName, ok := gpuStructName(t)
+
+		if !ok {
+			return "", 0, 0, 0, fmt.Errorf("struct %v is not listed in GPUStructs", t)
+		}
+
+		layout, err := std430Layout(t)
+
+		if err != nil {
+			return "", 0, 0, 0, err
+		}
+
+		return structName, 0, layout.Align, layout.Size, nil
+
+	}
+
+	return "", 0, 0, 0, fmt.Errorf("type %v has no GLSL equivalent", t)
+
+}
+
+// std430Layout lays the struct out by the std430 rules and fails if Go placed any field elsewhere
+func std430Layout(t reflect.Type) (glslLayout, error) {
+
+	layout := glslLayout{Align: 4}
+
+	var offset uintptr
+
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+
+		name := field.Tag.Get("glsl")
+
+		if name == "" {
+			return layout, fmt.Errorf("%v.%v: missing glsl struct tag", t.Name(), field.Name)
+		}
+
+		glslName, length, align, size, err := glslType(field.Type)
+
+		if err != nil {
+			return layout, fmt.Errorf("%v.%v: %w", t.Name(), field.Name, err)
+		}
+
+		offset = roundUp(offset, align)
+
+		if field.Offset != offset {
+			return layout, fmt.Errorf("%v.%v: Go offset %d, std430 offset %d", t.Name(), field.Name, field.Offset, offset)
+		}
+
+		layout.Fields = append(layout.Fields, glslField{Name: name, Type: glslName, Length: length, Offset: offset})
+		layout.Align = max(layout.Align, align)
+
+		offset += size
+
+	}
+
+	layout.Size = roundUp(offset, layout.Align)
+
+	if t.Size() != layout.Size {
+		return layout, fmt.Errorf("%v: Go size %d, std430 size %d", t.Name(), t.Size(), layout.Size)
+	}
+
+	return layout, nil
+
+}
+
+// CheckGPULayouts returns an error for the first GPU struct whose Go layout differs from std430
+func CheckGPULayouts() error {
+
+	for _, s := range GPUStructs {
+
+		if _, err := std430Layout(s.Type); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+func GenerateGLSL() (string, error) {
+
+	var output strings.Builder
+
+	output.WriteString("// Code generated by tools/glslgen from the world package. DO NOT EDIT.\n\n")
+
+	for _, constant := range GPUConstants() {
+		fmt.Fprintf(&output, "const %v %v = %v;\n", constant.Type, constant.Name, constant.Value)
+	}
+
+	for _, s := range GPUStructs {
+
+		layout, err := std430Layout(s.Type)
+
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(&output, "\n// %v, %d bytes\nstruct %v {\n", s.Type.Name(), layout.Size, s.Name)
+
+		for _, field := range layout.Fields {
+
+			if field.Length > 0 {
+				fmt.Fprintf(&output, "    %v %v[%d];\n", field.Type, field.Name, field.Length)
+				continue
+			}
+
+			fmt.Fprintf(&output, "    %v %v;\n", field.Type, field.Name)
+
+		}
+
+		output.WriteString("};\n")
+
+	}
+
+	return output.String(), nil
+
+}
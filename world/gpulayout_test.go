@@ -0,0 +1,119 @@
+package world
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	Shaders "VoxelRPG/shaders"
+)
+
+func TestCheckGPULayouts(t *testing.T) {
+
+	if err := CheckGPULayouts(); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestGPULayoutOffsets(t *testing.T) {
+
+	tests := []struct {
+		t       reflect.Type
+		size    uintptr
+		offsets map[string]uintptr
+	}{
+		{reflect.TypeFor[GridNodeFlatGPU](), 64, map[string]uintptr{"children": 0, "flags": 32, "size": 36, "light": 40, "metadata": 48}},
+		{reflect.TypeFor[MapEntry](), 16, map[string]uintptr{"Position": 0, "offset": 12}},
+		{reflect.TypeFor[LightGPU](), 48, map[string]uintptr{"position": 0, "type": 12, "color": 16, "range": 28, "direction": 32, "spotCos": 44}},
+		{reflect.TypeFor[MaterialGPU](), 16, map[string]uintptr{"flags": 0, "opacity": 4, "ior": 8, "emission": 12}},
+	}
+
+	for _, test := range tests {
+
+		layout, err := std430Layout(test.t)
+
+		if err != nil {
+			t.Errorf("%v: %v", test.t.Name(), err)
+			continue
+		}
+
+		if layout.Size != test.size {
+			t.Errorf("%v: size %d, want %d", test.t.Name(), layout.Size, test.size)
+		}
+
+		if len(layout.Fields) != len(test.offsets) {
+			t.Errorf("%v: %d fields, want %d", test.t.Name(), len(layout.Fields), len(test.offsets))
+		}
+
+		for _, field := range layout.Fields {
+			if want, ok := test.offsets[field.Name]; !ok || field.Offset != want {
+				t.Errorf("%v.%v: offset %d, want %d", test.t.Name(), field.Name, field.Offset, want)
+			}
+		}
+
+	}
+
+}
+
+func TestGPULayoutRejectsStd430Violations(t *testing.T) {
+
+	type misalignedVec3 struct {
+		A float32    `glsl:"a"`
+		V [3]float32 `glsl:"v"` // Go packs this at 4, std430 aligns vec3 to 16
+	}
+
+	type unpaddedVec3 struct {
+		V [3]float32 `glsl:"v"` // 12 bytes in Go, 16 in std430
+	}
+
+	type untagged struct {
+		A uint32
+	}
+
+	type unsupported struct {
+		A float64 `glsl:"a"`
+	}
+
+	tests := []struct {
+		t    reflect.Type
+		want string
+	}{
+		{reflect.TypeFor[misalignedVec3](), "offset"},
+		{reflect.TypeFor[unpaddedVec3](), "size"},
+		{reflect.TypeFor[untagged](), "missing glsl struct tag"},
+		{reflect.TypeFor[unsupported](), "no GLSL equivalent"},
+	}
+
+	for _, test := range tests {
+
+		_, err := std430Layout(test.t)
+
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v: error %v, want one mentioning %q", test.t.Name(), err, test.want)
+		}
+
+	}
+
+}
+
+// The checked-in GLSL must be what go generate ./world writes today
+func TestGeneratedGLSLIsCurrent(t *testing.T) {
+
+	generated, err := GenerateGLSL()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkedIn, err := Shaders.Files.ReadFile("include/gpu_types.glsl")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if generated != string(checkedIn) {
+		t.Errorf("shaders/include/gpu_types.glsl is stale, run go generate ./world")
+	}
+
+}
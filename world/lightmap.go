@@ -0,0 +1,456 @@
+package world
+
+import (
+	Log "VoxelRPG/logging"
+	Profiling "VoxelRPG/profiling"
+	"sort"
+)
+
+/* -- [[ Flood Fill Lighting ]] -- */
+
+// Sky and block light are stored as 4 bit levels per voxel, sky in the high nibble.
+// Light spreads one level dimmer per voxel, except full sky light which falls
+// straight down through air without losing strength.
+
+const MaxLightLevel uint8 = 15
+
+type LightChannel int
+
+const (
+	SkyLight LightChannel = iota
+	BlockLight
+)
+
+// Same order as the face index used by the shader, -X +X -Y +Y -Z +Z
+var faceDirections = [6]Vec3{
+	{X: -1}, {X: 1},
+	{Y: -1}, {Y: 1},
+	{Z: -1}, {Z: 1},
+}
+
+const faceDown = 2
+
+type lightNode struct {
+	chunk *Chunk
+	idx   int
+	level uint8
+}
+
+type lightPropagator struct {
+	world     *World
+	markDirty bool // Incremental updates mark the chunks they touch for re-upload
+}
+
+func (m Material) BlockLightLevel() uint8 {
+
+	level := m.LightRange / CHUNK_SCALE
+
+	if level > float32(MaxLightLevel) {
+		return MaxLightLevel
+	}
+
+	return uint8(level)
+
+}
+
+func getLight(chunk *Chunk, idx int, channel LightChannel) uint8 {
+
+	if channel == SkyLight {
+		return chunk.Light[idx] >> 4
+	}
+
+	return chunk.Light[idx] & 0xF
+
+}
+
+func (p *lightPropagator) setLight(chunk *Chunk, idx int, channel LightChannel, level uint8) {
+
+	if channel == SkyLight {
+		chunk.Light[idx] = (chunk.Light[idx] & 0x0F) | (level << 4)
+	} else {
+		chunk.Light[idx] = (chunk.Light[idx] & 0xF0) | level
+	}
+
+	if !p.markDirty {
+		return
+	}
+
+	p.world.MarkDirty(chunk)
+
+	// Neighbouring chunks bake the light of our border voxels into their faces
+
+	x, y, z := IndexToCoords(idx, CHUNK_SIZE)
+	local := [3]int{x, y, z}
+
+	for face, dir := range faceDirections {
+
+		axis := face / 2
+		offset := [3]int32{dir.X, dir.Y, dir.Z}[axis]
+
+		if (offset < 0 && local[axis] != 0) || (offset > 0 && local[axis] != CHUNK_SIZE-1) {
+			continue
+		}
+
+		if neighbour := p.world.ChunkMap[chunk.Position.Add(dir)]; neighbour != nil {
+			p.world.MarkDirty(neighbour)
+		}
+
+	}
+
+}
+
+// lightOpacity is how many extra levels a voxel absorbs, MaxLightLevel blocks light entirely
+func lightOpacity(chunk *Chunk, idx int) uint8 {
+
+	if !chunkGetVoxelBit(chunk.Voxels, idx) {
+		return 0
+	}
+
+	if chunk.Materials[idx].Material().Transparent {
+		return 1
+	}
+
+	return MaxLightLevel
+
+}
+
+func (w *World) neighbourVoxel(chunk *Chunk, idx int, dir Vec3) (*Chunk, int) {
+
+	x, y, z := IndexToCoords(idx, CHUNK_SIZE)
+
+	nx, ny, nz := x+int(dir.X), y+int(dir.Y), z+int(dir.Z)
+
+	if nx >= 0 && ny >= 0 && nz >= 0 && nx < CHUNK_SIZE && ny < CHUNK_SIZE && nz < CHUNK_SIZE {
+		return chunk, CoordsToIndex(nx, ny, nz, CHUNK_SIZE)
+	}
+
+	base := chunk.Position.MulScalar(int32(CHUNK_SIZE))
+
+	return w.voxelLocation(base.Add(Vec3{X: int32(nx), Y: int32(ny), Z: int32(nz)}))
+
+}
+
+func (p *lightPropagator) propagate(queue []lightNode, channel LightChannel) {
+
+	for head := 0; head < len(queue); head++ {
+
+		node := queue[head]
+		level := getLight(node.chunk, node.idx, channel)
+
+		if level <= 1 {
+			continue
+		}
+
+		for face, dir := range faceDirections {
+
+			neighbour, nIdx := p.world.neighbourVoxel(node.chunk, node.idx, dir)
+
+			if neighbour == nil {
+				continue
+			}
+
+			opacity := lightOpacity(neighbour, nIdx)
+
+			if level <= 1+opacity {
+				continue
+			}
+
+			next := level - 1 - opacity
+
+			if channel == SkyLight && face == faceDown && level == MaxLightLevel && opacity == 0 {
+				next = MaxLightLevel
+			}
+
+			if getLight(neighbour, nIdx, channel) >= next {
+				continue
+			}
+
+			p.setLight(neighbour, nIdx, channel, next)
+			queue = append(queue, lightNode{chunk: neighbour, idx: nIdx, level: next})
+
+		}
+
+	}
+
+}
+
+// remove clears the light which came from the voxel and returns the voxels which need to spread into the gap
+func (p *lightPropagator) remove(chunk *Chunk, idx int, channel LightChannel) []lightNode {
+
+	queue := []lightNode{{chunk: chunk, idx: idx, level: getLight(chunk, idx, channel)}}
+	var relight []lightNode
+
+	p.setLight(chunk, idx, channel, 0)
+
+	for head := 0; head < len(queue); head++ {
+
+		node := queue[head]
+
+		for face, dir := range faceDirections {
+
+			neighbour, nIdx := p.world.neighbourVoxel(node.chunk, node.idx, dir)
+
+			if neighbour == nil {
+				continue
+			}
+
+			nLevel := getLight(neighbour, nIdx, channel)
+
+			if nLevel == 0 {
+				continue
+			}
+
+			fellFromSky := channel == SkyLight && face == faceDown && node.level == MaxLightLevel && nLevel == MaxLightLevel
+
+			if nLevel < node.level || fellFromSky {
+				p.setLight(neighbour, nIdx, channel, 0)
+				queue = append(queue, lightNode{chunk: neighbour, idx: nIdx, level: nLevel})
+			} else {
+				relight = append(relight, lightNode{chunk: neighbour, idx: nIdx, level: nLevel})
+			}
+
+		}
+
+	}
+
+	return relight
+
+}
+
+/* -- [[ Full World Lighting ]] -- */
+
+func (w *World) ComputeLighting() {
+
+	lighting := Profiling.Start("world.lighting")
+
+	p := &lightPropagator{world: w}
+
+	for _, chunk := range w.Chunks {
+
+		if chunk.Light == nil {
+			chunk.Light = make([]uint8, FULL_CHUNK_SIZE)
+		} else {
+			clear(chunk.Light)
+		}
+
+	}
+
+	// Light sky columns from the top of the world down, chunks above first
+
+	sorted := make([]*Chunk, len(w.Chunks))
+	copy(sorted, w.Chunks)
+
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Position.Y > sorted[b].Position.Y
+	})
+
+	var columns []lightNode
+
+	for _, chunk := range sorted {
+
+		above := w.ChunkMap[chunk.Position.Add(Vec3{Y: 1})]
+
+		for x := 0; x < CHUNK_SIZE; x++ {
+			for z := 0; z < CHUNK_SIZE; z++ {
+
+				if above != nil && getLight(above, CoordsToIndex(x, 0, z, CHUNK_SIZE), SkyLight) != MaxLightLevel {
+					continue
+				}
+
+				for y := CHUNK_SIZE - 1; y >= 0; y-- {
+
+					idx := CoordsToIndex(x, y, z, CHUNK_SIZE)
+
+					if lightOpacity(chunk, idx) != 0 {
+						break
+					}
+
+					p.setLight(chunk, idx, SkyLight, MaxLightLevel)
+					columns = append(columns, lightNode{chunk: chunk, idx: idx, level: MaxLightLevel})
+
+				}
+
+			}
+		}
+
+	}
+
+	// Only column voxels next to darker voxels need to spread sideways
+
+	var skyQueue []lightNode
+
+	for _, node := range columns {
+
+		for _, dir := range faceDirections {
+
+			neighbour, nIdx := w.neighbourVoxel(node.chunk, node.idx, dir)
+
+			if neighbour != nil && getLight(neighbour, nIdx, SkyLight) < MaxLightLevel-1 && lightOpacity(neighbour, nIdx) < MaxLightLevel {
+				skyQueue = append(skyQueue, node)
+				break
+			}
+
+		}
+
+	}
+
+	columns = nil
+
+	p.propagate(skyQueue, SkyLight)
+
+	// Block light from emissive voxels
+
+	var blockQueue []lightNode
+
+	for _, chunk := range w.Chunks {
+
+		for idx, material := range chunk.Materials {
+
+			if material == MaterialAir || !chunkGetVoxelBit(chunk.Voxels, idx) {
+				continue
+			}
+
+			level := material.Material().BlockLightLevel()
+
+			if level == 0 {
+				continue
+			}
+
+			p.setLight(chunk, idx, BlockLight, level)
+			blockQueue = append(blockQueue, lightNode{chunk: chunk, idx: idx, level: level})
+
+		}
+
+	}
+
+	p.propagate(blockQueue, BlockLight)
+
+	Log.World.Info("Lighting propagated", "chunks", len(w.Chunks), "time", lighting.End())
+
+}
+
+/* -- [[ Incremental Lighting ]] -- */
+
+// relightVoxel updates the light around a voxel whose material just changed
+func (w *World) relightVoxel(chunk *Chunk, idx int) {
+
+	if chunk.Light == nil {
+		return
+	}
+
+	p := &lightPropagator{world: w, markDirty: true}
+
+	for _, channel := range []LightChannel{SkyLight, BlockLight} {
+
+		relight := p.remove(chunk, idx, channel)
+
+		if channel == BlockLight && chunkGetVoxelBit(chunk.Voxels, idx) {
+
+			if level := chunk.Materials[idx].Material().BlockLightLevel(); level > 0 {
+				p.setLight(chunk, idx, BlockLight, level)
+				relight = append(relight, lightNode{chunk: chunk, idx: idx, level: level})
+			}
+
+		}
+
+		p.propagate(relight, channel)
+
+	}
+
+}
+
+/* -- [[ Baking Light into the Octree ]] -- */
+
+func packFaceLight(light [2]uint32, face int, value uint8) [2]uint32 {
+
+	shift := uint((face % 4) * 8)
+
+	light[face/4] = (light[face/4] &^ (0xFF << shift)) | (uint32(value) << shift)
+
+	return light
+
+}
+
+func unpackFaceLight(light [2]uint32, face int) uint8 {
+
+	return uint8(light[face/4] >> uint((face%4)*8))
+
+}
+
+func maxPackedLight(a, b uint8) uint8 {
+
+	sky := max(a>>4, b>>4)
+	block := max(a&0xF, b&0xF)
+
+	return (sky << 4) | block
+
+}
+
+// bakeLight stores, for every face of every occupied node, the light of the voxel in front of it
+func (w *World) bakeLight(chunk *Chunk, nodes []GridNodeFlatGPU) {
+
+	if chunk.Light == nil {
+		return
+	}
+
+	leafLevel := GRID_SIZES - 1
+	leafStart := int(LevelStartIndices[leafLevel])
+
+	for idx := 0; idx < FULL_CHUNK_SIZE; idx++ {
+
+		node := &nodes[leafStart+idx]
+		node.Light = [2]uint32{}
+
+		if !DecodeFlags(node.Flags).Occupied {
+			continue
+		}
+
+		for face, dir := range faceDirections {
+
+			neighbour, nIdx := w.neighbourVoxel(chunk, idx, dir)
+
+			if neighbour == nil || neighbour.Light == nil {
+
+				// Faces on the edge of the world see the open sky
+
+				node.Light = packFaceLight(node.Light, face, MaxLightLevel<<4)
+				continue
+
+			}
+
+			node.Light = packFaceLight(node.Light, face, neighbour.Light[nIdx])
+
+		}
+
+	}
+
+	// Parents keep the brightest light of their children per face, for LOD hits
+
+	for level := leafLevel - 1; level >= 0; level-- {
+
+		start := int(LevelStartIndices[level])
+		end := int(LevelStartIndices[level+1])
+
+		for i := start; i < end; i++ {
+
+			node := &nodes[i]
+			node.Light = [2]uint32{}
+
+			for _, child := range node.Children {
+
+				if child == MaxUINT32 {
+					continue
+				}
+
+				for face := range faceDirections {
+					value := maxPackedLight(unpackFaceLight(node.Light, face), unpackFaceLight(nodes[child].Light, face))
+					node.Light = packFaceLight(node.Light, face, value)
+				}
+
+			}
+
+		}
+
+	}
+
+}
@@ -0,0 +1,251 @@
+package world
+
+import (
+	"testing"
+)
+
+// fillVoxels sets voxels of a test world without relighting, before ComputeLighting runs
+func fillVoxels(w *World, material MaterialID, voxels ...Vec3) {
+
+	for _, voxel := range voxels {
+		chunk, idx := w.voxelLocation(voxel)
+		chunkSetVoxelBit(chunk.Voxels, idx, true)
+		chunk.Materials[idx] = material
+	}
+
+}
+
+// newRoofedWorld is an empty chunk with a stone roof across y 20, open at x 5 z 5
+func newRoofedWorld() *World {
+
+	w := newTestWorld()
+
+	for x := int32(0); x < int32(CHUNK_SIZE); x++ {
+		for z := int32(0); z < int32(CHUNK_SIZE); z++ {
+
+			if x != 5 || z != 5 {
+				fillVoxels(w, MaterialStone, Vec3{x, 20, z})
+			}
+
+		}
+	}
+
+	return w
+
+}
+
+func lightAt(w *World, voxel Vec3, channel LightChannel) uint8 {
+
+	chunk, idx := w.voxelLocation(voxel)
+
+	return getLight(chunk, idx, channel)
+
+}
+
+type lightCase struct {
+	name    string
+	voxel   Vec3
+	channel LightChannel
+	want    uint8
+}
+
+func checkLight(t *testing.T, w *World, tests []lightCase) {
+
+	t.Helper()
+
+	for _, test := range tests {
+		if got := lightAt(w, test.voxel, test.channel); got != test.want {
+			t.Errorf("%v: light at %v is %d, want %d", test.name, test.voxel, got, test.want)
+		}
+	}
+
+}
+
+func TestSkyLightStopsAtOpaqueVoxels(t *testing.T) {
+
+	w := newRoofedWorld()
+	fillVoxels(w, MaterialStone, Vec3{20, 25, 20})
+
+	w.ComputeLighting()
+
+	checkLight(t, w, []lightCase{
+		{"above the roof", Vec3{10, 25, 10}, SkyLight, 15},
+		{"in the roof", Vec3{10, 20, 10}, SkyLight, 0},
+		{"under a stone, lit from the side", Vec3{20, 24, 20}, SkyLight, 14},
+		{"through the hole to the floor", Vec3{5, 0, 5}, SkyLight, 15},
+		{"beside the fallen column", Vec3{6, 0, 5}, SkyLight, 14},
+		{"under the roof, far from the hole", Vec3{30, 10, 30}, SkyLight, 0},
+	})
+
+	// Faces bake the light of the voxel in front of them, the edge of the world sees the sky
+
+	chunk := w.Chunks[0]
+	nodes := chunk.BuildNestedGrid()
+
+	w.bakeLight(chunk, nodes)
+
+	leafStart := int(LevelStartIndices[GRID_SIZES-1])
+
+	faces := []struct {
+		name  string
+		voxel Vec3
+		face  int
+		want  uint8
+	}{
+		{"roof top", Vec3{10, 20, 10}, 3, MaxLightLevel << 4},
+		{"roof bottom, far from the hole", Vec3{30, 20, 30}, faceDown, 0},
+		{"roof edge", Vec3{0, 20, 10}, 0, MaxLightLevel << 4},
+	}
+
+	for _, test := range faces {
+
+		_, idx := w.voxelLocation(test.voxel)
+
+		if got := unpackFaceLight(nodes[leafStart+idx].Light, test.face); got != test.want {
+			t.Errorf("%v: baked face %d of %v is %#x, want %#x", test.name, test.face, test.voxel, got, test.want)
+		}
+
+	}
+
+}
+
+func TestLightDropsThroughTransparentVoxels(t *testing.T) {
+
+	w := newTestWorld()
+
+	fillVoxels(w, MaterialLamp, Vec3{10, 10, 10})
+	fillVoxels(w, MaterialGlass, Vec3{12, 10, 10}, Vec3{5, 31, 5})
+
+	w.ComputeLighting()
+
+	checkLight(t, w, []lightCase{
+		{"lamp", Vec3{10, 10, 10}, BlockLight, 15},
+		{"air next to the lamp", Vec3{11, 10, 10}, BlockLight, 14},
+		{"air two from the lamp", Vec3{8, 10, 10}, BlockLight, 13},
+		{"glass two from the lamp", Vec3{12, 10, 10}, BlockLight, 12},
+		{"sky beside the glass", Vec3{4, 31, 5}, SkyLight, 15},
+		{"sky in the glass", Vec3{5, 31, 5}, SkyLight, 13},
+		{"sky under the glass", Vec3{5, 30, 5}, SkyLight, 14},
+	})
+
+}
+
+func TestEmissiveLightFalloff(t *testing.T) {
+
+	lamp := Vec3{16, 16, 16}
+	level := MaterialLamp.Material().BlockLightLevel()
+
+	w := newTestWorld()
+	fillVoxels(w, MaterialLamp, lamp)
+
+	w.ComputeLighting()
+
+	for distance := int32(0); distance < int32(CHUNK_SIZE)-lamp.X; distance++ {
+
+		want := uint8(0)
+
+		if int32(level) > distance {
+			want = level - uint8(distance)
+		}
+
+		voxel := lamp.Add(Vec3{X: distance})
+
+		if got := lightAt(w, voxel, BlockLight); got != want {
+			t.Errorf("block light %d voxels from the lamp is %d, want %d", distance, got, want)
+		}
+
+	}
+
+	if got := lightAt(w, lamp.Add(Vec3{X: 2, Y: -3, Z: 1}), BlockLight); got != level-6 {
+		t.Errorf("block light 6 steps from the lamp diagonally is %d, want %d", got, level-6)
+	}
+
+}
+
+func TestPlaceAndRemoveRestoresLight(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		voxel    Vec3
+		material MaterialID
+	}{
+		{"stone in the open", Vec3{20, 25, 20}, MaterialStone},
+		{"stone plugging the hole", Vec3{5, 20, 5}, MaterialStone},
+		{"glass plugging the hole", Vec3{5, 20, 5}, MaterialGlass},
+		{"stone under the fallen column", Vec3{5, 10, 5}, MaterialStone},
+		{"lamp under the roof", Vec3{10, 10, 10}, MaterialLamp},
+	}
+
+	for _, test := range tests {
+
+		w := newRoofedWorld()
+		w.FloodFillLighting = true
+
+		w.ComputeLighting()
+
+		chunk := w.Chunks[0]
+		original := append([]uint8(nil), chunk.Light...)
+
+		w.SetVoxel(test.voxel, test.material)
+
+		changed := 0
+
+		for idx := range original {
+			if chunk.Light[idx] != original[idx] {
+				changed++
+			}
+		}
+
+		if changed == 0 {
+			t.Errorf("%v: placing the block changed no light", test.name)
+		}
+
+		w.SetVoxel(test.voxel, MaterialAir)
+
+		for idx := range original {
+
+			if chunk.Light[idx] != original[idx] {
+				x, y, z := IndexToCoords(idx, CHUNK_SIZE)
+				t.Errorf("%v: light at %d %d %d is %#x after removing the block, want %#x", test.name, x, y, z, chunk.Light[idx], original[idx])
+				break
+			}
+
+		}
+
+	}
+
+}
+
+func TestPackFaceLightRoundTrip(t *testing.T) {
+
+	var light [2]uint32
+
+	for face := range faceDirections {
+		light = packFaceLight(light, face, uint8(face*40+7))
+	}
+
+	for face := range faceDirections {
+		if got := unpackFaceLight(light, face); got != uint8(face*40+7) {
+			t.Errorf("face %d unpacked %d, want %d", face, got, face*40+7)
+		}
+	}
+
+	// Repacking one face leaves the others alone
+
+	light = packFaceLight(light, 4, 0xFF)
+
+	for face := range faceDirections {
+
+		want := uint8(face*40 + 7)
+
+		if face == 4 {
+			want = 0xFF
+		}
+
+		if got := unpackFaceLight(light, face); got != want {
+			t.Errorf("after repacking face 4, face %d unpacked %d, want %d", face, got, want)
+		}
+
+	}
+
+}
@@ -0,0 +1,159 @@
+package world
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+type LightType uint32
+
+const (
+	LightPoint LightType = iota
+	LightSpot
+)
+
+type LightID uint32
+
+type Light struct {
+	Type     LightType
+	Position mgl32.Vec3
+	Color    mgl32.Vec3 // Pre-multiplied by intensity
+	Range    float32
+
+	Direction mgl32.Vec3 // Spot lights only
+	SpotAngle float32    // Half angle of the spot cone in degrees
+}
+
+/* -- [[ Light Structs for sending to GPU ]] -- */
+
+type LightGPU struct {
+	Position  [3]float32 `glsl:"position"`
+	Type      uint32     `glsl:"type"`
+	Color     [3]float32 `glsl:"color"`
+	Range     float32    `glsl:"range"`
+	Direction [3]float32 `glsl:"direction"`
+	SpotCos   float32    `glsl:"spotCos"`
+}
+
+/* -- [[ Light Registry ]] -- */
+
+func (w *World) AddLight(light Light) LightID {
+
+	if w.Lights == nil {
+		w.Lights = map[LightID]Light{}
+	}
+
+	w.nextLightID++
+	id := w.nextLightID
+
+	w.Lights[id] = light
+	w.LightsDirty = true
+
+	return id
+
+}
+
+func (w *World) RemoveLight(id LightID) {
+
+	if _, ok := w.Lights[id]; !ok {
+		return
+	}
+
+	delete(w.Lights, id)
+	w.LightsDirty = true
+
+}
+
+// voxelLight keeps the point light of an emissive voxel in sync with its material
+func (w *World) voxelLight(voxel Vec3, material MaterialID) {
+
+	if id, ok := w.VoxelLights[voxel]; ok {
+		w.RemoveLight(id)
+		delete(w.VoxelLights, voxel)
+	}
+
+	m := material.Material()
+
+	if material == MaterialAir || !m.Emissive() {
+		return
+	}
+
+	if w.VoxelLights == nil {
+		w.VoxelLights = map[Vec3]LightID{}
+	}
+
+	center := mgl32.Vec3{
+		(float32(voxel.X) + 0.5) * CHUNK_SCALE,
+		(float32(voxel.Y) + 0.5) * CHUNK_SCALE,
+		(float32(voxel.Z) + 0.5) * CHUNK_SCALE,
+	}
+
+	w.VoxelLights[voxel] = w.AddLight(Light{
+		Type:     LightPoint,
+		Position: center,
+		Color:    mgl32.Vec3{float32(m.R) / 255, float32(m.G) / 255, float32(m.B) / 255}.Mul(m.Emission),
+		Range:    m.LightRange,
+	})
+
+}
+
+// registerChunkLights adds lights for emissive voxels placed by world generation
+func (w *World) registerChunkLights(chunk *Chunk) {
+
+	base := chunk.Position.MulScalar(int32(CHUNK_SIZE))
+
+	for idx, material := range chunk.Materials {
+
+		if material == MaterialAir || !material.Material().Emissive() || !chunkGetVoxelBit(chunk.Voxels, idx) {
+			continue
+		}
+
+		x, y, z := IndexToCoords(idx, CHUNK_SIZE)
+		w.voxelLight(base.Add(Vec3{X: int32(x), Y: int32(y), Z: int32(z)}), material)
+
+	}
+
+}
+
+func (w *World) BuildLightTable() []LightGPU {
+
+	// Always keep one entry so the SSBO is never empty
+
+	table := make([]LightGPU, 0, len(w.Lights)+1)
+
+	for _, light := range w.Lights {
+
+		direction := light.Direction
+
+		if direction.Len() > 0 {
+			direction = direction.Normalize()
+		}
+
+		table = append(table, LightGPU{
+			Position:  light.Position,
+			Type:      uint32(light.Type),
+			Color:     light.Color,
+			Range:     light.Range,
+			Direction: direction,
+			SpotCos:   float32(math.Cos(float64(mgl32.DegToRad(light.SpotAngle)))),
+		})
+
+	}
+
+	if len(table) == 0 {
+		table = append(table, LightGPU{})
+	}
+
+	return table
+
+}
+
+func (w *World) UploadLights() {
+
+	w.Backend.UploadLights(w.BuildLightTable())
+	w.Backend.SetWorldParams(w.worldParams())
+
+	w.LightsDirty = false
+
+}
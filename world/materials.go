@@ -0,0 +1,93 @@
+package world
+
+type MaterialID uint8
+
+const (
+	MaterialAir MaterialID = iota
+	MaterialDefault
+	MaterialStone
+	MaterialGlass
+	MaterialWater
+	MaterialLeaves
+	MaterialTorch
+	MaterialLava
+	MaterialLamp
+)
+
+const (
+	MaterialFlagTransparent uint32 = 1 << 0
+)
+
+type Material struct {
+	Name string
+
+	R, G, B uint8
+
+	Transparent bool
+	Opacity     float32 // How much of the surface colour a transparent voxel adds, per voxel crossed
+	IOR         float32 // Index of refraction, 0 disables refraction and fresnel reflections
+
+	Emission   float32 // Strength of the light emitted in the material colour, 0 is not emissive
+	LightRange float32 // Reach of the point light registered for emissive voxels, in world units
+}
+
+// Indexed by MaterialID, MaterialDefault keeps the random per voxel colours
+var Materials = []Material{
+	MaterialAir:     {Name: "air", Transparent: true},
+	MaterialDefault: {Name: "default", Opacity: 1},
+	MaterialStone:   {Name: "stone", R: 120, G: 120, B: 125, Opacity: 1},
+	MaterialGlass:   {Name: "glass", R: 200, G: 225, B: 235, Transparent: true, Opacity: 0.15, IOR: 1.5},
+	MaterialWater:   {Name: "water", R: 40, G: 90, B: 150, Transparent: true, Opacity: 0.12, IOR: 1.33},
+	MaterialLeaves:  {Name: "leaves", R: 60, G: 130, B: 45, Transparent: true, Opacity: 0.6},
+	MaterialTorch:   {Name: "torch", R: 255, G: 170, B: 80, Opacity: 1, Emission: 2.0, LightRange: 0.5},
+	MaterialLava:    {Name: "lava", R: 255, G: 90, B: 20, Opacity: 1, Emission: 1.5, LightRange: 0.35},
+	MaterialLamp:    {Name: "lamp", R: 255, G: 245, B: 220, Opacity: 1, Emission: 3.0, LightRange: 0.75},
+}
+
+/* -- [[ Material Structs for sending to GPU ]] -- */
+
+type MaterialGPU struct {
+	Flags    uint32  `glsl:"flags"`
+	Opacity  float32 `glsl:"opacity"`
+	IOR      float32 `glsl:"ior"`
+	Emission float32 `glsl:"emission"`
+}
+
+func BuildMaterialTable() []MaterialGPU {
+
+	table := make([]MaterialGPU, len(Materials))
+
+	for i, material := range Materials {
+
+		flags := uint32(0)
+
+		if material.Transparent {
+			flags |= MaterialFlagTransparent
+		}
+
+		table[i] = MaterialGPU{
+			Flags:    flags,
+			Opacity:  material.Opacity,
+			IOR:      material.IOR,
+			Emission: material.Emission,
+		}
+
+	}
+
+	return table
+
+}
+
+func (m Material) Emissive() bool {
+	return m.Emission > 0
+}
+
+func (id MaterialID) Material() Material {
+
+	if int(id) >= len(Materials) {
+		return Materials[MaterialDefault]
+	}
+
+	return Materials[id]
+
+}
@@ -18,6 +18,7 @@ func (chunk *Chunk) NewLevel(gridID int, nodeList []GridNodeFlatGPU) ([]GridNode
 	idxMax := Size * Size * Size
 
 	startIndex := CalculateTotalNodes(GridSizes, gridID-1)
+	seed := hash3D([3]int32{chunk.Position.X, chunk.Position.Y, chunk.Position.Z})
 
 	for idx := 0; idx < int(idxMax); idx++ {
 
@@ -30,11 +31,13 @@ func (chunk *Chunk) NewLevel(gridID int, nodeList []GridNodeFlatGPU) ([]GridNode
 		}
 
 		flags := uint32(0)
+		material := MaterialAir
 
 		if cIndex == -1 {
 
 			if chunkGetVoxelBit(chunk.Voxels, idx) {
 				flags |= FlagOccupied
+				material = chunk.Materials[idx]
 			}
 			flags |= FlagLeaf
 
@@ -68,6 +71,16 @@ func (chunk *Chunk) NewLevel(gridID int, nodeList []GridNodeFlatGPU) ([]GridNode
 				if f.Occupied {
 					children[i] = uint32(childGlobalIdx)
 					flags |= FlagOccupied
+
+					// Parents only keep a material when all of their children share it
+
+					childMaterial := MaterialID(childNode.Metadata.Material)
+
+					if material == MaterialAir {
+						material = childMaterial
+					} else if material != childMaterial {
+						material = MaterialDefault
+					}
 				}
 
 			}
@@ -78,7 +91,7 @@ func (chunk *Chunk) NewLevel(gridID int, nodeList []GridNodeFlatGPU) ([]GridNode
 			Flags:    flags,
 			Children: children,
 			Size:     S,
-			Metadata: VoxelMetadata(idx),
+			Metadata: MaterialMetadata(seed+uint32(parentGlobalIdx), material),
 		}
 
 	}
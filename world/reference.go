@@ -0,0 +1,276 @@
+package world
+
+import (
+	"image"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/* -- [[ CPU Reference Path ]] -- */
+
+// The reference path mirrors octree_traverse.frag on the CPU so output can be
+// compared offline without a GL context. It always works at full voxel detail,
+// the shader's LOD cutoff is not reproduced.
+
+const ReferenceMaxDistance = float32(MAX_STEPS) // Chunks are one unit across
+
+type AOQuality int32
+
+const (
+	AOOff AOQuality = iota
+	AOLow
+	AOHigh
+)
+
+type RayHit struct {
+	Voxel    Vec3
+	Normal   Vec3
+	Position mgl32.Vec3
+	T        float32
+	Steps    int32 // Voxels stepped through, also set on a miss
+}
+
+type ReferenceCamera struct {
+	Pos   mgl32.Vec3
+	Front mgl32.Vec3
+}
+
+func floorDiv(a, b int32) int32 {
+
+	q := a / b
+
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+
+	return q
+
+}
+
+/* -- [[ Voxel Occupancy ]] -- */
+
+func (w *World) VoxelOccupied(voxel Vec3) bool {
+
+	chunk, idx := w.voxelLocation(voxel)
+
+	if chunk == nil {
+		return false
+	}
+
+	return chunkGetVoxelBit(chunk.Voxels, idx)
+
+}
+
+/* -- [[ Ray Traversal (3D DDA over voxels) ]] -- */
+
+func (w *World) TraceRay(ro, rd mgl32.Vec3, maxDistance float32) (RayHit, bool) {
+
+	voxelSize := CHUNK_SCALE
+
+	p := ro.Mul(1 / voxelSize)
+
+	voxel := Vec3{
+		X: int32(math.Floor(float64(p.X()))),
+		Y: int32(math.Floor(float64(p.Y()))),
+		Z: int32(math.Floor(float64(p.Z()))),
+	}
+
+	var step [3]int32
+	var tMax, tDelta [3]float32
+
+	for axis := 0; axis < 3; axis++ {
+
+		tMax[axis] = float32(math.Inf(1))
+		tDelta[axis] = float32(math.Inf(1))
+
+		if rd[axis] == 0 {
+			continue
+		}
+
+		tDelta[axis] = float32(math.Abs(float64(1 / rd[axis])))
+		base := float32(math.Floor(float64(p[axis])))
+
+		if rd[axis] > 0 {
+			step[axis] = 1
+			tMax[axis] = (base + 1 - p[axis]) / rd[axis]
+		} else {
+			step[axis] = -1
+			tMax[axis] = (base - p[axis]) / rd[axis]
+		}
+
+	}
+
+	maxT := maxDistance / voxelSize
+
+	t := float32(0)
+	normal := Vec3{}
+	steps := int32(0)
+
+	for ; t <= maxT; steps++ {
+
+		if w.VoxelOccupied(voxel) {
+			return RayHit{
+				Voxel:    voxel,
+				Normal:   normal,
+				Position: ro.Add(rd.Mul(t * voxelSize)),
+				T:        t * voxelSize,
+				Steps:    steps,
+			}, true
+		}
+
+		axis := 0
+
+		if tMax[1] < tMax[axis] {
+			axis = 1
+		}
+
+		if tMax[2] < tMax[axis] {
+			axis = 2
+		}
+
+		t = tMax[axis]
+		tMax[axis] += tDelta[axis]
+
+		switch axis {
+		case 0:
+			voxel.X += step[0]
+			normal = Vec3{X: -step[0]}
+		case 1:
+			voxel.Y += step[1]
+			normal = Vec3{Y: -step[1]}
+		case 2:
+			voxel.Z += step[2]
+			normal = Vec3{Z: -step[2]}
+		}
+
+	}
+
+	return RayHit{Steps: steps}, false
+
+}
+
+/* -- [[ Ambient Occlusion ]] -- */
+
+func vertexAO(side1, side2, corner float32) float32 {
+
+	if side1 > 0 && side2 > 0 {
+		return 0
+	}
+
+	return (3 - (side1 + side2 + corner)) / 3
+
+}
+
+func mixF32(a, b, t float32) float32 {
+	return a + (b-a)*t
+}
+
+func clampF32(v, min, max float32) float32 {
+	return float32(math.Min(math.Max(float64(v), float64(min)), float64(max)))
+}
+
+// AmbientOcclusion samples the voxels in front of the hit face, it must match
+// ambientOcclusion in octree_traverse.frag.
+func (w *World) AmbientOcclusion(hit RayHit, quality AOQuality, strength float32) float32 {
+
+	if quality <= AOOff {
+		return 1
+	}
+
+	occupancy := func(v Vec3) float32 {
+		if w.VoxelOccupied(v) {
+			return 1
+		}
+		return 0
+	}
+
+	front := hit.Voxel.Add(hit.Normal)
+
+	t1 := Vec3{X: 1}
+	if hit.Normal.X != 0 {
+		t1 = Vec3{Y: 1}
+	}
+
+	t2 := Vec3{Z: 1}
+	if hit.Normal.Z != 0 {
+		t2 = Vec3{Y: 1}
+	}
+
+	s1n := occupancy(front.Sub(t1))
+	s1p := occupancy(front.Add(t1))
+	s2n := occupancy(front.Sub(t2))
+	s2p := occupancy(front.Add(t2))
+
+	var ao float32
+
+	if quality == AOLow {
+
+		ao = 1 - (s1n+s1p+s2n+s2p)/4
+
+	} else {
+
+		local := hit.Position.Mul(1 / CHUNK_SCALE).Sub(mgl32.Vec3{float32(hit.Voxel.X), float32(hit.Voxel.Y), float32(hit.Voxel.Z)})
+
+		u := clampF32(local.Dot(mgl32.Vec3{float32(t1.X), float32(t1.Y), float32(t1.Z)}), 0, 1)
+		v := clampF32(local.Dot(mgl32.Vec3{float32(t2.X), float32(t2.Y), float32(t2.Z)}), 0, 1)
+
+		c00 := vertexAO(s1n, s2n, occupancy(front.Sub(t1).Sub(t2)))
+		c10 := vertexAO(s1p, s2n, occupancy(front.Add(t1).Sub(t2)))
+		c01 := vertexAO(s1n, s2p, occupancy(front.Sub(t1).Add(t2)))
+		c11 := vertexAO(s1p, s2p, occupancy(front.Add(t1).Add(t2)))
+
+		ao = mixF32(mixF32(c00, c10, u), mixF32(c01, c11, u), v)
+
+	}
+
+	return mixF32(1, ao, strength)
+
+}
+
+/* -- [[ Reference Rendering ]] -- */
+
+func (cam ReferenceCamera) InvView() mgl32.Mat4 {
+
+	return mgl32.LookAtV(cam.Pos, cam.Pos.Add(cam.Front), mgl32.Vec3{0, 1, 0}).Inv()
+
+}
+
+// CameraRay matches the ray generation in main() of octree_traverse.frag, px/py are in pixels from the top left
+func CameraRay(invView mgl32.Mat4, px, py, width, height int) mgl32.Vec3 {
+
+	u := (float32(px)+0.5)/float32(width)*2 - 1
+	v := (float32(height-1-py)+0.5)/float32(height)*2 - 1
+
+	u *= float32(width) / float32(height)
+
+	rdView := mgl32.Vec3{u, v, -1}.Normalize()
+
+	return invView.Mul4x1(rdView.Vec4(0)).Vec3().Normalize()
+
+}
+
+// RenderAmbientOcclusion writes the AO term of every primary hit, misses are left black
+func (w *World) RenderAmbientOcclusion(cam ReferenceCamera, width, height int, quality AOQuality) *image.Gray {
+
+	output := image.NewGray(image.Rect(0, 0, width, height))
+	invView := cam.InvView()
+
+	for py := 0; py < height; py++ {
+		for px := 0; px < width; px++ {
+
+			hit, ok := w.TraceRay(cam.Pos, CameraRay(invView, px, py, width, height), ReferenceMaxDistance)
+
+			if !ok {
+				continue
+			}
+
+			ao := w.AmbientOcclusion(hit, quality, 1)
+			output.Pix[py*output.Stride+px] = uint8(clampF32(ao, 0, 1) * 255)
+
+		}
+	}
+
+	return output
+
+}
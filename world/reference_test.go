@@ -0,0 +1,111 @@
+package world
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// newTestWorld is a single empty chunk at the origin with the given voxels filled
+func newTestWorld(voxels ...Vec3) *World {
+
+	chunk := &Chunk{
+		Voxels:       make([]uint8, (FULL_CHUNK_SIZE+7)/8),
+		Materials:    make([]MaterialID, FULL_CHUNK_SIZE),
+		OctreeOffset: MaxUINT32,
+	}
+
+	w := &World{
+		Chunks:   []*Chunk{chunk},
+		ChunkMap: map[Vec3]*Chunk{{}: chunk},
+	}
+
+	for _, voxel := range voxels {
+		_, idx := w.voxelLocation(voxel)
+		chunkSetVoxelBit(chunk.Voxels, idx, true)
+	}
+
+	return w
+
+}
+
+func TestAmbientOcclusion(t *testing.T) {
+
+	// A floor voxel seen from straight above, the AO samples sit on the layer above it
+
+	floor := Vec3{5, 5, 5}
+
+	corner := []Vec3{floor, {4, 6, 5}, {5, 6, 4}}
+
+	enclosed := []Vec3{floor}
+
+	for x := int32(4); x <= 6; x++ {
+		for z := int32(4); z <= 6; z++ {
+			if x != 5 || z != 5 {
+				enclosed = append(enclosed, Vec3{x, 6, z})
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		voxels []Vec3
+		want   map[AOQuality]float32
+	}{
+		{"open", []Vec3{floor}, map[AOQuality]float32{AOOff: 1, AOLow: 1, AOHigh: 1}},
+		{"corner", corner, map[AOQuality]float32{AOOff: 1, AOLow: 0.5, AOHigh: 7.0 / 12.0}},
+		{"enclosed", enclosed, map[AOQuality]float32{AOOff: 1, AOLow: 0, AOHigh: 0}},
+	}
+
+	origin := mgl32.Vec3{5.5, 20, 5.5}.Mul(CHUNK_SCALE)
+
+	for _, test := range tests {
+
+		w := newTestWorld(test.voxels...)
+
+		hit, ok := w.TraceRay(origin, mgl32.Vec3{0, -1, 0}, ReferenceMaxDistance)
+
+		if !ok || hit.Voxel != floor || hit.Normal != (Vec3{Y: 1}) {
+			t.Fatalf("%v: hit %+v (ok %v), want the top of %v", test.name, hit, ok, floor)
+		}
+
+		for _, quality := range []AOQuality{AOOff, AOLow, AOHigh} {
+
+			got := w.AmbientOcclusion(hit, quality, 1)
+
+			if math.Abs(float64(got-test.want[quality])) > 1e-4 {
+				t.Errorf("%v quality %d: AO %v, want %v", test.name, quality, got, test.want[quality])
+			}
+
+		}
+
+	}
+
+}
+
+func TestAmbientOcclusionStrength(t *testing.T) {
+
+	w := newTestWorld(Vec3{5, 5, 5}, Vec3{4, 6, 5}, Vec3{5, 6, 4})
+
+	hit, _ := w.TraceRay(mgl32.Vec3{5.5, 20, 5.5}.Mul(CHUNK_SCALE), mgl32.Vec3{0, -1, 0}, ReferenceMaxDistance)
+
+	if got := w.AmbientOcclusion(hit, AOLow, 0.5); math.Abs(float64(got-0.75)) > 1e-4 {
+		t.Errorf("AO at half strength %v, want 0.75", got)
+	}
+
+}
+
+func TestRenderAmbientOcclusionMisses(t *testing.T) {
+
+	w := newTestWorld()
+
+	image := w.RenderAmbientOcclusion(ReferenceCamera{Pos: mgl32.Vec3{0.5, 0.5, 0.5}, Front: mgl32.Vec3{0, 0, -1}}, 4, 4, AOHigh)
+
+	for _, value := range image.Pix {
+		if value != 0 {
+			t.Fatalf("empty world rendered %v, misses should stay black", image.Pix)
+		}
+	}
+
+}
@@ -2,11 +2,9 @@ package world
 
 import (
 	Log "VoxelRPG/logging"
-	"errors"
-	"fmt"
 	"math"
+	"sync/atomic"
 
-	"github.com/go-gl/gl/v4.6-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
@@ -22,6 +20,8 @@ const (
 	VOXEL_SIZE int = 1
 	GRID_SIZES int = 6
 
+	MAX_STEPS int = 24 // Chunks a ray may cross in the shader before giving up
+
 	FULL_CHUNK_SIZE int = (int(CHUNK_SIZE) * int(VERTICAL_CHUNK_SIZE) * int(CHUNK_SIZE))
 
 	MaxUINT32 uint32 = 0xFFFFFFFF
@@ -40,15 +40,21 @@ type Vec3 struct{ X, Y, Z int32 }
 func (v Vec3) Add(o Vec3) Vec3 {
 	return Vec3{X: v.X + o.X, Y: v.Y + o.Y, Z: v.Z + o.Z}
 }
+func (v Vec3) Sub(o Vec3) Vec3 {
+	return Vec3{X: v.X - o.X, Y: v.Y - o.Y, Z: v.Z - o.Z}
+}
 func (v Vec3) MulScalar(o int32) Vec3 {
 	return Vec3{X: v.X * o, Y: v.Y * o, Z: v.Z * o}
 }
 
 type Chunk struct {
-	Position Vec3
-	Voxels   []uint8
+	Position  Vec3
+	Voxels    []uint8
+	Materials []MaterialID
+	Light     []uint8 // Flood fill light levels, nil until ComputeLighting runs
 
 	OctreeOffset uint32
+	Dirty        bool
 }
 
 type World struct {
@@ -56,12 +62,24 @@ type World struct {
 	RenderDistance  *int
 	LastCameraChunk Vec3
 
-	Chunks []*Chunk
+	Chunks      []*Chunk
+	ChunkMap    map[Vec3]*Chunk
+	DirtyChunks []*Chunk
+
+	Lights      map[LightID]Light
+	VoxelLights map[Vec3]LightID
+	LightsDirty bool
+	nextLightID LightID
+
+	FloodFillLighting bool // Propagate sky and block light on the CPU and bake it into the octree
 
-	CombinedSSBO         uint32
-	WorldInfoSSBO        uint32
-	WorldInfoOffsetsSSBO uint32
-	DebugResultSSBO      uint32
+	Backend      RenderBackend
+	NodeCapacity int // Octree nodes allocated in the backend for every chunk
+
+	stopping atomic.Bool // Set from another goroutine to make Populate give up early
+
+	octrees     map[*Chunk][]GridNodeFlatGPU // Octree of every loaded chunk, see storeOctree
+	octreeNodes int
 }
 
 /* -- [[ Camera Chunking ]] -- */
@@ -126,10 +144,8 @@ var (
 func init() {
 
 	GridSizes = GenerateGridSizes(CHUNK_SIZE, GRID_SIZES)
-	Log.NewLog("Grid sizes per level", GridSizes)
 
 	NodesRequired = CalculateTotalNodes(GridSizes, GRID_SIZES)
-	Log.NewLog("Nodes required", NodesRequired)
 
 	LevelStartIndices = make([]int32, len(GridSizes))
 
@@ -139,24 +155,24 @@ func init() {
 
 	}
 
-	Log.NewLog("Indicies:", LevelStartIndices)
+	Log.World.Debug("Octree layout", "gridSizes", GridSizes, "nodes", NodesRequired, "levelStarts", LevelStartIndices)
 }
 
 /* -- [[ Grid Structs for sending to GPU ]] -- */
 
 type GridMetadata struct {
-	R uint32
-	G uint32
-	B uint32
-	_ uint32
+	R        uint32 `glsl:"R"`
+	G        uint32 `glsl:"G"`
+	B        uint32 `glsl:"B"`
+	Material uint32 `glsl:"material"`
 }
 
 type GridNodeFlatGPU struct {
-	Children [8]uint32
-	Flags    uint32
-	Size     int32
-	_        [2]uint32
-	Metadata GridMetadata
+	Children [8]uint32    `glsl:"children"`
+	Flags    uint32       `glsl:"flags"`
+	Size     int32        `glsl:"size"`
+	Light    [2]uint32    `glsl:"light"` // Baked sky/block light per face, one byte each in -X +X -Y +Y -Z +Z order
+	Metadata GridMetadata `glsl:"metadata"`
 }
 type ChunkInfo struct {
 	Key        Vec3
@@ -183,7 +199,6 @@ func GenerateGridSizes(maxSize, levels int) []int32 {
 	sizes := make([]int32, levels)
 	size := maxSize
 	for i := levels - 1; i >= 0; i-- {
-		Log.NewLog(i)
 		sizes[i] = int32(size)
 		if size > 1 {
 			size = size / 2
@@ -207,28 +222,38 @@ func CalculateTotalNodes(sizes []int32, maxLevel int) int {
 /* -- [[ Generate Hashmap for GLSL shader to lookup (For chunk lookup) ]] -- */
 
 type MapEntry struct {
-	Position   Vec3
-	RootOffset uint32
+	Position   Vec3   `glsl:"Position"`
+	RootOffset uint32 `glsl:"offset"`
 }
 
+// Shared with the shader through the generated shaders/include/gpu_types.glsl
+const (
+	hashMixA       uint32 = 0x7feb352d
+	hashMixB       uint32 = 0x846ca68b
+	hashGolden     uint32 = 0x9e3779b9
+	hashSignOffset uint32 = 0x80000000
+)
+
+var hash2Multiplier = [3]int32{0x27d4eb2d, 0x165667b1, 0x1b873593}
+
 func hashUint32(x uint32) uint32 {
 	x ^= x >> 16
-	x *= 0x7feb352d
+	x *= hashMixA
 	x ^= x >> 15
-	x *= 0x846ca68b
+	x *= hashMixB
 	x ^= x >> 16
 	return x
 }
 
 func hash3D(pos [3]int32) uint32 {
 	// Convert signed to unsigned by offsetting
-	ux := uint32(int64(pos[0]) + 0x80000000)
-	uy := uint32(int64(pos[1]) + 0x80000000)
-	uz := uint32(int64(pos[2]) + 0x80000000)
+	ux := uint32(int64(pos[0]) + int64(hashSignOffset))
+	uy := uint32(int64(pos[1]) + int64(hashSignOffset))
+	uz := uint32(int64(pos[2]) + int64(hashSignOffset))
 
 	h := hashUint32(ux)
-	h ^= hashUint32(uy) + 0x9e3779b9 + (h << 6) + (h >> 2)
-	h ^= hashUint32(uz) + 0x9e3779b9 + (h << 6) + (h >> 2)
+	h ^= hashUint32(uy) + hashGolden + (h << 6) + (h >> 2)
+	h ^= hashUint32(uz) + hashGolden + (h << 6) + (h >> 2)
 	return h
 }
 
@@ -239,16 +264,16 @@ func hash1(pos [3]int32) uint32 {
 func hash2(pos [3]int32) uint32 {
 	// multiply components by constants same as GLSL
 	return hash3D([3]int32{
-		pos[0] * int32(0x27d4eb2d),
-		pos[1] * int32(0x165667b1),
-		pos[2] * int32(0x1b873593),
+		pos[0] * hash2Multiplier[0],
+		pos[1] * hash2Multiplier[1],
+		pos[2] * hash2Multiplier[2],
 	})
 }
 
 func BuildPerfectHashTable(chunks []*Chunk) ([]uint32, []MapEntry, error) {
 	N := len(chunks)
 	if N == 0 {
-		return nil, nil, errors.New("empty chunk slice")
+		return nil, nil, ErrNoChunks
 	}
 
 	// Precompute keys as hash3D of chunk positions
@@ -308,7 +333,7 @@ func BuildPerfectHashTable(chunks []*Chunk) ([]uint32, []MapEntry, error) {
 			}
 		}
 		if !found {
-			return nil, nil, errors.New("failed to find displacement for a bucket")
+			return nil, nil, ErrNoDisplacement
 		}
 
 		h := hash2(bucket[0].key) % uint32(N)
@@ -341,32 +366,44 @@ func BuildPerfectHashTable(chunks []*Chunk) ([]uint32, []MapEntry, error) {
 	return displacements, table, nil
 }
 
-func GLErrorToString(err uint32) string {
-	switch err {
-	case gl.NO_ERROR:
-		return "NO_ERROR"
-	case gl.INVALID_ENUM:
-		return "INVALID_ENUM"
-	case gl.INVALID_VALUE:
-		return "INVALID_VALUE"
-	case gl.INVALID_OPERATION:
-		return "INVALID_OPERATION"
-	case gl.INVALID_FRAMEBUFFER_OPERATION:
-		return "INVALID_FRAMEBUFFER_OPERATION"
-	case gl.OUT_OF_MEMORY:
-		return "OUT_OF_MEMORY"
-	case gl.CONTEXT_LOST:
-		return "CONTEXT_LOST"
-	default:
-		return fmt.Sprintf("Unknown error: 0x%x", err)
-	}
+type HashTableStats struct {
+	Size       int     `json:"size"`
+	Filled     int     `json:"filled"`
+	LoadFactor float64 `json:"loadFactor"`
+
+	MaxDisplacement  uint32 `json:"maxDisplacement"`
+	DisplacedBuckets int    `json:"displacedBuckets"` // Buckets that needed a displacement above 0
 }
 
-func CheckGLError(context string) bool {
-	err := gl.GetError()
-	if err != gl.NO_ERROR {
-		Log.NewLog("OpenGL error in", context, ":", GLErrorToString(err))
-		return true
+// HashTableStats rebuilds the chunk lookup table the shader gets and describes it
+func (w *World) HashTableStats() (HashTableStats, error) {
+
+	displacements, table, err := BuildPerfectHashTable(w.Chunks)
+
+	if err != nil {
+		return HashTableStats{}, err
+	}
+
+	stats := HashTableStats{Size: len(table)}
+
+	for _, entry := range table {
+		if entry.RootOffset != math.MaxUint32 {
+			stats.Filled++
+		}
+	}
+
+	for _, displacement := range displacements {
+
+		if displacement > 0 {
+			stats.DisplacedBuckets++
+		}
+
+		stats.MaxDisplacement = max(stats.MaxDisplacement, displacement)
+
 	}
-	return false
+
+	stats.LoadFactor = float64(stats.Filled) / float64(max(stats.Size, 1))
+
+	return stats, nil
+
 }
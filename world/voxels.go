@@ -0,0 +1,103 @@
+package world
+
+import (
+	Profiling "VoxelRPG/profiling"
+)
+
+/* -- [[ Voxel Editing ]] -- */
+
+// Voxel positions are in global voxel coordinates, chunk position * CHUNK_SIZE + local position
+
+func (w *World) voxelLocation(voxel Vec3) (*Chunk, int) {
+
+	size := int32(CHUNK_SIZE)
+
+	chunkPos := Vec3{
+		X: floorDiv(voxel.X, size),
+		Y: floorDiv(voxel.Y, size),
+		Z: floorDiv(voxel.Z, size),
+	}
+
+	chunk := w.ChunkMap[chunkPos]
+
+	if chunk == nil {
+		return nil, -1
+	}
+
+	local := voxel.Sub(chunkPos.MulScalar(size))
+
+	return chunk, CoordsToIndex(int(local.X), int(local.Y), int(local.Z), CHUNK_SIZE)
+
+}
+
+func (w *World) GetVoxel(voxel Vec3) MaterialID {
+
+	chunk, idx := w.voxelLocation(voxel)
+
+	if chunk == nil || !chunkGetVoxelBit(chunk.Voxels, idx) {
+		return MaterialAir
+	}
+
+	return chunk.Materials[idx]
+
+}
+
+// SetVoxel places a voxel, MaterialAir clears it. Returns false outside of loaded chunks.
+// The chunk octree is rebuilt on the next FlushDirtyChunks.
+func (w *World) SetVoxel(voxel Vec3, material MaterialID) bool {
+
+	chunk, idx := w.voxelLocation(voxel)
+
+	if chunk == nil {
+		return false
+	}
+
+	chunkSetVoxelBit(chunk.Voxels, idx, material != MaterialAir)
+	chunk.Materials[idx] = material
+
+	w.voxelLight(voxel, material)
+	w.MarkDirty(chunk)
+
+	if w.FloodFillLighting {
+		w.relightVoxel(chunk, idx)
+	}
+
+	return true
+
+}
+
+func (w *World) MarkDirty(chunk *Chunk) {
+
+	if chunk.Dirty {
+		return
+	}
+
+	chunk.Dirty = true
+	w.DirtyChunks = append(w.DirtyChunks, chunk)
+
+}
+
+// FlushDirtyChunks rebuilds and uploads every chunk edited since the last flush, must run on the GL thread
+func (w *World) FlushDirtyChunks() {
+
+	if w.Backend == nil {
+		return
+	}
+
+	if w.LightsDirty {
+		w.UploadLights()
+	}
+
+	if len(w.DirtyChunks) == 0 {
+		return
+	}
+
+	defer Profiling.Start("world.flush").End()
+
+	for _, chunk := range w.DirtyChunks {
+		chunk.Rebuild(w)
+	}
+
+	w.DirtyChunks = w.DirtyChunks[:0]
+
+}
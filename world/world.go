@@ -1,30 +1,21 @@
 package world
 
 import (
+	"fmt"
+
 	Log "VoxelRPG/logging"
+	Profiling "VoxelRPG/profiling"
 	"runtime"
-	"strconv"
 	"sync"
-	"time"
 	"unsafe"
 
-	"github.com/go-gl/gl/v4.6-core/gl"
 	"github.com/go-gl/mathgl/mgl32"
 )
 
-type OctreeChanInput struct {
-	Input *Chunk
-	Value []GridNodeFlatGPU
-}
-
 var (
-	MainWorld            *World
-	CombinedOctree       map[*Chunk][]GridNodeFlatGPU = map[*Chunk][]GridNodeFlatGPU{}
-	CombinedOctreeLength uint32                       = 0
+	MainWorld *World
 
 	OctreeNodeByteSize int
-
-	CombinedOctreeChan = make(chan OctreeChanInput)
 )
 
 func init() {
@@ -36,65 +27,105 @@ func init() {
 		RenderDistance: RENDER_DISTANCE_POINTER,
 	}
 
-	var LastCombinedOctreeLength uint32 = 0
+}
 
-	go func() {
-		for {
-			for Data := range CombinedOctreeChan {
+/* -- [[ Octree Store ]] -- */
 
-				CombinedOctree[Data.Input] = Data.Value
-				Data.Input.OctreeOffset = uint32((len(CombinedOctree) - 1) * len(CombinedOctree[Data.Input]))
-				CombinedOctreeLength += uint32(len(CombinedOctree[Data.Input]))
+// Every loaded chunk owns NodesRequired nodes of the backend's node buffer, at its index in
+// w.Chunks. Only the render thread touches the store, workers hand octrees over with the chunk.
 
-			}
+// storeOctree keeps a chunk's latest octree, replacing the one it had
+func (w *World) storeOctree(chunk *Chunk, nodes []GridNodeFlatGPU) {
 
-			if CombinedOctreeLength != LastCombinedOctreeLength {
+	if w.octrees == nil {
+		w.octrees = map[*Chunk][]GridNodeFlatGPU{}
+	}
 
-				Log.NewLog("Updated Octree, New Size:", CombinedOctreeLength)
+	w.octreeNodes -= len(w.octrees[chunk])
+	w.octrees[chunk] = nodes
+	w.octreeNodes += len(nodes)
 
-			}
-		}
-	}()
+}
+
+// RemoveOctree drops a chunk's octree, leaving its nodes in the backend untouched
+func (w *World) RemoveOctree(chunk *Chunk) {
+
+	w.octreeNodes -= len(w.octrees[chunk])
+	delete(w.octrees, chunk)
+
+	chunk.OctreeOffset = MaxUINT32
 
 }
 
-func (w *World) Update(shaderProgram uint32) {
+// Octree is the stored octree of a loaded chunk, nil for chunks this world does not hold
+func (w *World) Octree(chunk *Chunk) []GridNodeFlatGPU {
+	return w.octrees[chunk]
+}
 
-	w.UploadCombinedOctree(shaderProgram)
+// OctreeNodes is how many nodes of the backend's node buffer the loaded chunks use
+func (w *World) OctreeNodes() int {
+	return w.octreeNodes
+}
+
+func (w *World) Update() error {
+
+	return w.UploadCombinedOctree()
 
 }
 
-func (w *World) UpdateIfNeeded(shaderProgram uint32, viewProjection mgl32.Mat4, cameraPos mgl32.Vec3) {
+func (w *World) UpdateIfNeeded(viewProjection mgl32.Mat4, cameraPos mgl32.Vec3) {
 	currentChunk := GetCameraChunk(cameraPos)
 
 	if currentChunk == w.LastCameraChunk {
 		return // Skip expensive update
 	}
 
-	Log.NewLog("New Camera ChunkPos:", currentChunk, "Pos:", cameraPos)
+	Log.World.Debug("Camera entered a new chunk", "chunk", currentChunk, "position", cameraPos)
 
 	w.LastCameraChunk = currentChunk
-	w.Update(shaderProgram)
+
+	if err := w.Update(); err != nil {
+		Log.World.Error("World update failed", "error", err)
+	}
 }
 
-func (w *World) Populate(shaderProgram uint32) {
+// generatedChunk is a chunk and its octree, both built on a generation worker
+type generatedChunk struct {
+	index int
+	chunk *Chunk
+	nodes []GridNodeFlatGPU
+}
 
-	rDistance := *w.RenderDistance
+// Populate generates every chunk in the render distance, returning ErrGenerationStopped when
+// StopGeneration is called before it finishes. The loaded chunks are only replaced on success.
+func (w *World) Populate() error {
 
-	ChunksLength := (rDistance * rDistance * rDistance)
+	if w.Backend == nil {
+		return ErrNoBackend
+	}
+
+	generated, err := w.generateChunks(*w.RenderDistance)
+
+	if err != nil {
+		return err
+	}
 
-	w.Chunks = make([]*Chunk, ChunksLength)
+	w.loadChunks(generated)
 
-	Log.NewLog("Loading '" + strconv.Itoa(ChunksLength) + "' chunks")
+	return nil
 
-	StartedWorldGen := time.Now()
+}
 
-	type WorldOutput struct {
-		index int
-		value *Chunk
-	}
+// generateChunks builds the chunks of a render distance on the workers, without touching the world
+func (w *World) generateChunks(rDistance int) ([]generatedChunk, error) {
+
+	ChunksLength := (rDistance * rDistance * rDistance)
+
+	Log.World.Info("Loading chunks", "count", ChunksLength)
 
-	var resultOutput = make(chan WorldOutput, ChunksLength)
+	generate := Profiling.Start("world.generate")
+
+	var resultOutput = make(chan generatedChunk, ChunksLength)
 
 	batchSize := ChunksLength / WORLD_WORKERS
 
@@ -114,15 +145,18 @@ func (w *World) Populate(shaderProgram uint32) {
 
 			defer worldAwait.Done()
 
-			for chunkIndex := start; chunkIndex < end; chunkIndex++ {
+			for chunkIndex := start; chunkIndex < end && !w.stopping.Load(); chunkIndex++ {
 
 				x := chunkIndex % rDistance
 				y := (chunkIndex / rDistance) % rDistance
 				z := chunkIndex / (rDistance * rDistance)
 
-				resultOutput <- WorldOutput{
+				chunk := NewChunk(Vec3{int32(x), int32(y), int32(z)})
+
+				resultOutput <- generatedChunk{
 					index: chunkIndex,
-					value: NewChunk(Vec3{int32(x), int32(y), int32(z)}),
+					chunk: chunk,
+					nodes: chunk.SetupOctree(),
 				}
 
 			}
@@ -136,16 +170,110 @@ func (w *World) Populate(shaderProgram uint32) {
 		close(resultOutput)
 	}()
 
+	generated := make([]generatedChunk, ChunksLength)
+
 	for val := range resultOutput {
+		generated[val.index] = val // Keeps draining after a stop, so the workers can finish
+	}
+
+	if w.stopping.Load() {
+		generate.End()
+		return nil, ErrGenerationStopped
+	}
+
+	Log.World.Info("World generated", "chunks", ChunksLength, "time", generate.End())
+
+	return generated, nil
+
+}
+
+// loadChunks replaces the loaded chunks with generated ones and uploads their octrees
+func (w *World) loadChunks(generated []generatedChunk) {
+
+	defer Profiling.Start("world.load").End()
+
+	w.Chunks = make([]*Chunk, len(generated))
+	w.ChunkMap = make(map[Vec3]*Chunk, len(generated))
+	w.octrees = make(map[*Chunk][]GridNodeFlatGPU, len(generated))
+	w.octreeNodes = 0
+
+	for _, val := range generated {
+
+		val.chunk.OctreeOffset = uint32(val.index * NodesRequired)
+
+		w.Chunks[val.index] = val.chunk
+		w.ChunkMap[val.chunk.Position] = val.chunk
+		w.storeOctree(val.chunk, val.nodes)
+
+		w.registerChunkLights(val.chunk)
+
+	}
 
-		/* -- [[ Upload the Chunk Octree data to SSBO ]] -- */
-		val.value.Upload()
+	// Flood fill lighting needs every chunk loaded before it can be baked
 
-		w.Chunks[val.index] = val.value
+	if w.FloodFillLighting {
 
+		w.ComputeLighting()
+
+		for _, chunk := range w.Chunks {
+			w.bakeLight(chunk, w.octrees[chunk])
+		}
+
+	}
+
+	for _, chunk := range w.Chunks {
+		w.UploadChunk(chunk)
+	}
+
+	Log.World.Debug(Profiling.Report())
+
+}
+
+// MaxRenderDistance is the largest render distance whose chunks fit in the octree SSBO
+func MaxRenderDistance() int {
+
+	distance := 1
+
+	for (distance+1)*(distance+1)*(distance+1) <= TOTAL_RENDERED_CHUNKS {
+		distance++
+	}
+
+	return distance
+
+}
+
+// SetRenderDistance generates the world again at the new distance and swaps it in, leaving the
+// loaded chunks and the backend untouched if generation fails. Must run on the GL thread.
+func (w *World) SetRenderDistance(distance int) error {
+
+	if w.Backend == nil {
+		return ErrNoBackend
+	}
+
+	if distance < 1 || distance > MaxRenderDistance() {
+		return fmt.Errorf("%w: %d is outside 1-%d", ErrInvalidRenderDistance, distance, MaxRenderDistance())
+	}
+
+	Log.World.Info("Changing render distance", "from", *w.RenderDistance, "to", distance)
+
+	generated, err := w.generateChunks(distance)
+
+	if err != nil {
+		return err
+	}
+
+	for voxel, id := range w.VoxelLights {
+		w.RemoveLight(id)
+		delete(w.VoxelLights, voxel)
 	}
 
-	Log.NewLog("World generation took:", time.Since(StartedWorldGen))
+	w.DirtyChunks = w.DirtyChunks[:0]
+
+	*w.RenderDistance = distance
+
+	w.loadChunks(generated)
+
+	return w.Update()
 
 }
 
@@ -161,14 +289,16 @@ func (w *World) GetRootOffsets() []uint32 {
 
 }
 
-func (w *World) UploadCombinedOctree(shaderProgram uint32) {
+func (w *World) UploadCombinedOctree() error {
 
 	//gpuNodes := BuildCombinedOctreeData(w.Chunks)
 
-	w.UploadCombinedOctreeSSBO(shaderProgram)
+	err := w.SendGPUBuffers()
 
 	runtime.GC()
 
+	return err
+
 }
 
 func (w *World) GetChunkPositions() []int32 {
@@ -191,79 +321,94 @@ func (w *World) GetChunkPositions() []int32 {
 
 }
 
-func (w *World) UploadCombinedOctreeSSBO(shaderProgram uint32) {
+// SendGPUBuffers uploads the chunk lookup table, materials and lights. If the lookup table can
+// not be built nothing is uploaded and the GPU keeps rendering with the previous buffers.
+func (w *World) SendGPUBuffers() error {
 
-	if w.WorldInfoSSBO == 0 {
-		gl.GenBuffers(1, &w.WorldInfoSSBO)
-		if w.WorldInfoSSBO == 0 {
-			Log.NewLog("Failed to generate World Info SSBO")
-		}
+	if w.Backend == nil {
+		return nil
 	}
 
-	if w.WorldInfoOffsetsSSBO == 0 {
-		gl.GenBuffers(1, &w.WorldInfoOffsetsSSBO)
-		if w.WorldInfoOffsetsSSBO == 0 {
-			Log.NewLog("Failed to generate World Info Offset SSBO")
-		}
+	defer Profiling.Start("world.upload").End()
+
+	offsets, chunkInfo, _, err := w.GetChunkInfo()
+
+	if err != nil {
+		return err
 	}
 
-	w.SendGPUBuffers(shaderProgram)
+	w.Backend.UploadChunkIndex(offsets, chunkInfo)
+	w.Backend.UploadMaterials(BuildMaterialTable())
+
+	w.UploadLights()
+
+	return nil
+
 }
 
-func (w *World) SendGPUBuffers(shaderProgram uint32) {
+// worldParams also sets the light count, so it is sent again whenever the lights change
+func (w *World) worldParams() WorldParams {
 
-	if w.CombinedSSBO == 0 {
-		return
+	return WorldParams{
+		ChunkSize:  float32(CHUNK_SIZE),
+		ChunkScale: CHUNK_SCALE,
+		Lights:     uint32(len(w.Lights)),
 	}
 
-	offsets, chunkInfo, numBuckets := w.GetChunkInfo()
+}
 
-	/* -- [[ Send over the amount of chunks to render ]] -- */
+func (w *World) GetChunkInfo() ([]uint32, []MapEntry, int, error) {
 
-	gl.Uniform1ui(gl.GetUniformLocation(shaderProgram, gl.Str("numChunks\x00")), uint32(len(w.Chunks)))
-	gl.Uniform1f(gl.GetUniformLocation(shaderProgram, gl.Str("chunkSize\x00")), float32(CHUNK_SIZE))
-	gl.Uniform1f(gl.GetUniformLocation(shaderProgram, gl.Str("chunkScale\x00")), CHUNK_SCALE)
-	gl.Uniform1i(gl.GetUniformLocation(shaderProgram, gl.Str("numBuckets\x00")), int32(numBuckets))
+	defer Profiling.Start("world.hash").End()
 
-	/* -- [[ Send over the chunk information itself ]] -- */
+	Displacement, MapEntries, err := BuildPerfectHashTable(w.Chunks)
 
-	/* -- [[ Send over the Chunk Info (Chunk Key & Chunk Root Offsets) itself ]] -- */
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("building the chunk lookup table for %d chunks: %w", len(w.Chunks), err)
+	}
 
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, w.WorldInfoSSBO)
+	return Displacement, MapEntries, len(w.Chunks), nil
 
-	gl.BufferData(
-		gl.SHADER_STORAGE_BUFFER,
-		len(chunkInfo)*int(unsafe.Sizeof(chunkInfo[0])),
-		gl.Ptr(chunkInfo), gl.STATIC_DRAW,
-	)
+}
 
-	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 1, w.WorldInfoSSBO)
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+/* -- [[ Shutdown ]] -- */
 
-	/* -- [[ Send over the Chunk Info (Offsets) itself ]] -- */
+// StopGeneration makes a Populate in progress return early, safe to call from any goroutine
+func (w *World) StopGeneration() {
+	w.stopping.Store(true)
+}
 
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, w.WorldInfoOffsetsSSBO)
+// AllocateNodes makes room in the backend for nodes octree nodes, dropping any uploaded chunks
+func (w *World) AllocateNodes(nodes int) error {
 
-	gl.BufferData(
-		gl.SHADER_STORAGE_BUFFER,
-		len(offsets)*int(unsafe.Sizeof(offsets[0])),
-		gl.Ptr(offsets), gl.STATIC_DRAW,
-	)
+	if w.Backend == nil {
+		return ErrNoBackend
+	}
 
-	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 2, w.WorldInfoOffsetsSSBO)
-	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, 0)
+	if err := w.Backend.AllocateNodes(nodes); err != nil {
+		return err
+	}
+
+	w.NodeCapacity = nodes
+
+	return nil
 
 }
 
-func (w *World) GetChunkInfo() ([]uint32, []MapEntry, int) {
+// Close flushes edited chunks and releases the backend, must run on the render thread once
+// nothing generates chunks anymore
+func (w *World) Close() {
 
-	Displacement, MapEntries, err := BuildPerfectHashTable(w.Chunks)
+	w.StopGeneration()
 
-	if err != nil {
-		panic("GetChunkInfo() error:" + err.Error())
+	if w.Backend != nil {
+		w.FlushDirtyChunks()
 	}
 
-	return Displacement, MapEntries, len(w.Chunks)
+	if w.Backend != nil {
+		w.Backend.Close()
+		w.Backend = nil
+	}
 
 }
 
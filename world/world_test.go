@@ -0,0 +1,77 @@
+package world
+
+import (
+	"errors"
+	"testing"
+)
+
+// newRecordedWorld is a world of 2x2x2 chunks uploading into a RecordingBackend, with room for
+// no more chunks than that
+func newRecordedWorld(t *testing.T) (*World, *RecordingBackend) {
+
+	totalChunks := TOTAL_RENDERED_CHUNKS
+
+	t.Cleanup(func() {
+		TOTAL_RENDERED_CHUNKS = totalChunks
+	})
+
+	TOTAL_RENDERED_CHUNKS = 8
+
+	distance := 2
+	backend := NewRecordingBackend()
+
+	w := &World{RenderDistance: &distance, Backend: backend}
+
+	if err := w.AllocateNodes(NodesRequired * TOTAL_RENDERED_CHUNKS); err != nil {
+		t.Fatal(err)
+	}
+
+	return w, backend
+
+}
+
+func TestWorldCloseThenReuse(t *testing.T) {
+
+	w, _ := newRecordedWorld(t)
+
+	if err := w.Populate(); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Close()
+
+	// Close released the backend, so changing the distance has to fail cleanly
+
+	if err := w.SetRenderDistance(1); !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("SetRenderDistance after Close returned %v, want ErrNoBackend", err)
+	}
+
+	// Edits after attaching a new backend rebuild into the same store
+
+	backend := NewRecordingBackend()
+	w.Backend = backend
+
+	if err := w.AllocateNodes(NodesRequired * TOTAL_RENDERED_CHUNKS); err != nil {
+		t.Fatal(err)
+	}
+
+	chunk := w.ChunkMap[Vec3{}]
+
+	if !w.SetVoxel(Vec3{1, 1, 1}, MaterialDefault) {
+		t.Fatal("SetVoxel missed the loaded chunk")
+	}
+
+	w.FlushDirtyChunks()
+
+	if uploads := backend.CallsTo("UploadNodes"); len(uploads) != 1 || uploads[0].Offset != chunk.OctreeOffset {
+		t.Errorf("rebuild after Close uploaded %+v, want one upload at offset %d", uploads, chunk.OctreeOffset)
+	}
+
+	if len(backend.Errors) != 0 {
+		t.Errorf("backend errors: %v", backend.Errors)
+	}
+
+	w.Close()
+	w.Close()
+
+}